@@ -0,0 +1,420 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultAnalyticsPrefixLength is how many characters of a query are recorded
+	defaultAnalyticsPrefixLength = 10
+	// defaultAnalyticsMinCount hides rarer queries from reports
+	defaultAnalyticsMinCount = 3
+	// maxAnalyticsKeys bounds how many distinct query prefixes, and how many
+	// distinct selected values, are counted per action
+	maxAnalyticsKeys = 1000
+	// analyticsReportSize is how many queries and selections a report lists per action
+	analyticsReportSize = 10
+	// maxReportedDeadOptions is how many never selected options a report lists per action
+	maxReportedDeadOptions = 100
+	// analyticsSaveInterval is how often changed analytics are persisted
+	analyticsSaveInterval = time.Minute
+	// analyticsPath is the admin endpoint serving the analytics report
+	analyticsPath = "/debug/analytics"
+)
+
+// actionAnalytics aggregates the searches and selections of a single action ID.
+// Queries are only kept as folded prefixes, and nothing identifies who searched.
+type actionAnalytics struct {
+	Searches          int            `json:"searches"`
+	ZeroResults       int            `json:"zeroResults"`
+	Queries           map[string]int `json:"queries,omitempty"`           // searches by query prefix
+	ZeroResultQueries map[string]int `json:"zeroResultQueries,omitempty"` // searches returning no options, by query prefix
+	Selections        map[string]int `json:"selections,omitempty"`        // selections by option value
+}
+
+// analyticsStore aggregates search analytics per action ID, with optional
+// on-disk persistence
+type analyticsStore struct {
+	mu           sync.Mutex
+	saveMu       sync.Mutex // serializes saves, so an older snapshot never replaces a newer one
+	path         string
+	prefixLength int
+	minCount     int
+	actions      map[string]*actionAnalytics
+	changes      uint64 // incremented whenever the analytics change
+	saved        uint64 // value of changes when the analytics were last persisted
+}
+
+// analytics is the process-wide analytics store; nil disables analytics
+var analytics *analyticsStore
+
+// newAnalyticsStore creates an empty analytics store recording queries
+// truncated to prefixLength characters and reporting those searched at least
+// minCount times. If path is non-empty the store is persisted to that file.
+func newAnalyticsStore(path string, prefixLength, minCount int) *analyticsStore {
+	return &analyticsStore{
+		path:         path,
+		prefixLength: prefixLength,
+		minCount:     minCount,
+		actions:      make(map[string]*actionAnalytics),
+	}
+}
+
+// queryPrefix folds a query as it is matched, collapses its whitespace and
+// truncates it to length characters
+func queryPrefix(query string, length int) string {
+	folded := []rune(strings.Join(strings.Fields(foldSearchText(query)), " "))
+	if len(folded) > length {
+		folded = folded[:length]
+	}
+	return strings.TrimSpace(string(folded))
+}
+
+// action returns the analytics of an action ID, creating them if needed. The caller must hold s.mu.
+func (s *analyticsStore) action(actionID string) *actionAnalytics {
+	a := s.actions[actionID]
+	if a == nil {
+		a = &actionAnalytics{}
+		s.actions[actionID] = a
+	}
+	return a
+}
+
+// incrementCount adds one to a bounded count, ignoring new keys once the limit is reached
+func incrementCount(counts *map[string]int, key string) {
+	if *counts == nil {
+		*counts = make(map[string]int)
+	}
+	if _, ok := (*counts)[key]; ok || len(*counts) < maxAnalyticsKeys {
+		(*counts)[key]++
+	}
+}
+
+// recordSearch notes a search of a catalog entry and how many options it returned
+func (s *analyticsStore) recordSearch(actionID, query string, results int) {
+	if s == nil {
+		return
+	}
+	prefix := queryPrefix(query, s.prefixLength)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.changes++
+	a := s.action(actionID)
+	a.Searches++
+	if prefix != "" {
+		incrementCount(&a.Queries, prefix)
+	}
+	if results == 0 {
+		a.ZeroResults++
+		if prefix != "" {
+			incrementCount(&a.ZeroResultQueries, prefix)
+		}
+	}
+}
+
+// recordSelection notes that an option of a catalog entry was selected
+func (s *analyticsStore) recordSelection(actionID, value string) {
+	if s == nil || value == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.changes++
+	incrementCount(&s.action(actionID).Selections, value)
+}
+
+// load reads previously persisted analytics from disk. A missing file is not an error.
+func (s *analyticsStore) load() error {
+	if s == nil || s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading analytics file: %w", err)
+	}
+
+	actions := make(map[string]*actionAnalytics)
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return fmt.Errorf("parsing analytics JSON: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.actions = actions
+	s.changes++
+	s.saved = s.changes
+	return nil
+}
+
+// save persists the analytics to disk if they changed since the last save,
+// replacing the previous file atomically
+func (s *analyticsStore) save() error {
+	if s == nil || s.path == "" {
+		return nil
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	if s.changes == s.saved {
+		s.mu.Unlock()
+		return nil
+	}
+	changes := s.changes
+	data, err := json.Marshal(s.actions)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding analytics: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path, ".analytics-*.json", data); err != nil {
+		return fmt.Errorf("saving analytics: %w", err)
+	}
+
+	s.mu.Lock()
+	s.saved = changes
+	s.mu.Unlock()
+	return nil
+}
+
+// saveEvery persists the analytics at every interval. Searches are too
+// frequent to save the file on each one.
+func (s *analyticsStore) saveEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.save(); err != nil {
+			log.Printf("Error saving analytics: %v", err)
+		}
+	}
+}
+
+// analyticsCount is a query prefix or option value with how often it was
+// searched for or selected
+type analyticsCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// actionReport summarizes the analytics of an action ID
+type actionReport struct {
+	ActionID             string           `json:"actionId"`
+	Searches             int              `json:"searches"`
+	ZeroResults          int              `json:"zeroResults"`
+	TopQueries           []analyticsCount `json:"topQueries"`
+	TopZeroResultQueries []analyticsCount `json:"topZeroResultQueries"`
+	Selections           int              `json:"selections"`
+	TopSelections        []analyticsCount `json:"topSelections"`
+	DeadOptionCount      int              `json:"deadOptionCount"`
+	DeadOptions          []string         `json:"deadOptions"` // catalog values never selected, in catalog order
+}
+
+// analyticsReport summarizes the analytics of every action ID that has been
+// searched or is in a catalog
+type analyticsReport struct {
+	Actions []actionReport `json:"actions"`
+}
+
+// topCounts returns the largest counts of at least minCount, highest first
+// and then by key
+func topCounts(counts map[string]int, minCount int) []analyticsCount {
+	top := []analyticsCount{}
+	for key, count := range counts {
+		if count >= minCount {
+			top = append(top, analyticsCount{Key: key, Count: count})
+		}
+	}
+	slices.SortFunc(top, func(a, b analyticsCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Key, b.Key))
+	})
+	return top[:min(len(top), analyticsReportSize)]
+}
+
+// report summarizes the analytics against the given catalogs, which provide
+// the options that have never been selected
+func (s *analyticsStore) report(catalogs []*catalogIndex) analyticsReport {
+	// Catalog option values by action ID, deduplicated and in catalog order
+	optionValues := make(map[string][]string)
+	seen := make(map[string]map[string]bool)
+	addValues := func(actionID string, options []Option) {
+		if seen[actionID] == nil {
+			seen[actionID] = make(map[string]bool)
+		}
+		for _, opt := range options {
+			if !seen[actionID][opt.Value] {
+				seen[actionID][opt.Value] = true
+				optionValues[actionID] = append(optionValues[actionID], opt.Value)
+			}
+		}
+	}
+	for _, c := range catalogs {
+		for _, entry := range c.entries {
+			addValues(entry.ActionID, entry.Options)
+			keys := make([]string, 0, len(entry.OptionsByValue))
+			for key := range entry.OptionsByValue {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				addValues(entry.ActionID, entry.OptionsByValue[key])
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	actionIDs := make([]string, 0, len(optionValues)+len(s.actions))
+	for actionID := range optionValues {
+		actionIDs = append(actionIDs, actionID)
+	}
+	for actionID := range s.actions {
+		if _, ok := optionValues[actionID]; !ok {
+			actionIDs = append(actionIDs, actionID)
+		}
+	}
+	slices.Sort(actionIDs)
+
+	report := analyticsReport{Actions: []actionReport{}}
+	for _, actionID := range actionIDs {
+		a := s.actions[actionID]
+		if a == nil {
+			a = &actionAnalytics{}
+		}
+
+		r := actionReport{
+			ActionID:             actionID,
+			Searches:             a.Searches,
+			ZeroResults:          a.ZeroResults,
+			TopQueries:           topCounts(a.Queries, s.minCount),
+			TopZeroResultQueries: topCounts(a.ZeroResultQueries, s.minCount),
+			TopSelections:        topCounts(a.Selections, 1),
+			DeadOptions:          []string{},
+		}
+		for _, count := range a.Selections {
+			r.Selections += count
+		}
+		for _, value := range optionValues[actionID] {
+			if a.Selections[value] > 0 {
+				continue
+			}
+			r.DeadOptionCount++
+			if len(r.DeadOptions) < maxReportedDeadOptions {
+				r.DeadOptions = append(r.DeadOptions, value)
+			}
+		}
+		report.Actions = append(report.Actions, r)
+	}
+	return report
+}
+
+// formatCounts lists counts as `"key" (count)`, separated by commas
+func formatCounts(counts []analyticsCount) string {
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		parts[i] = fmt.Sprintf("%q (%d)", c.Key, c.Count)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeAnalyticsReport writes a report as plain text
+func writeAnalyticsReport(w io.Writer, report analyticsReport) {
+	if len(report.Actions) == 0 {
+		fmt.Fprintln(w, "No analytics recorded")
+		return
+	}
+
+	for _, r := range report.Actions {
+		fmt.Fprintf(w, "%s: %d search(es), %d with no results, %d selection(s)\n", r.ActionID, r.Searches, r.ZeroResults, r.Selections)
+		if len(r.TopQueries) > 0 {
+			fmt.Fprintf(w, "  Top queries: %s\n", formatCounts(r.TopQueries))
+		}
+		if len(r.TopZeroResultQueries) > 0 {
+			fmt.Fprintf(w, "  No results: %s\n", formatCounts(r.TopZeroResultQueries))
+		}
+		if len(r.TopSelections) > 0 {
+			fmt.Fprintf(w, "  Most selected: %s\n", formatCounts(r.TopSelections))
+		}
+		if r.DeadOptionCount > 0 {
+			line := strings.Join(r.DeadOptions, ", ")
+			if more := r.DeadOptionCount - len(r.DeadOptions); more > 0 {
+				line += fmt.Sprintf(" and %d more", more)
+			}
+			fmt.Fprintf(w, "  Never selected (%d): %s\n", r.DeadOptionCount, line)
+		}
+	}
+}
+
+// handleAnalyticsReport serves the analytics report as JSON, with the
+// catalogs of every app providing the options never selected
+func handleAnalyticsReport(apps []*slackApp) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if analytics == nil {
+			http.Error(w, "Analytics disabled", http.StatusNotFound)
+			return
+		}
+
+		catalogs := make([]*catalogIndex, len(apps))
+		for i, app := range apps {
+			catalogs[i] = app.catalog.current()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(analytics.report(catalogs)); err != nil {
+			log.Printf("Error encoding analytics report: %v", err)
+		}
+	}
+}
+
+// runAnalyticsReport prints the report of the persisted analytics against the
+// configured catalogs, for the --report flag
+func runAnalyticsReport(w io.Writer, config Config) error {
+	if config.AnalyticsFile == "" {
+		return errors.New("ANALYTICS_FILE is required to report analytics")
+	}
+
+	store := newAnalyticsStore(config.AnalyticsFile, config.AnalyticsPrefixLength, config.AnalyticsMinCount)
+	if err := store.load(); err != nil {
+		return err
+	}
+
+	var files []string
+	if config.servesDefaultApp() || len(config.Apps) == 0 {
+		files = append(files, config.ConfigFile)
+	}
+	for _, app := range config.Apps {
+		files = append(files, app.ConfigFile)
+	}
+
+	catalogs := make([]*catalogIndex, len(files))
+	for i, file := range files {
+		catalog := &catalogStore{}
+		if err := catalog.load(file); err != nil {
+			return fmt.Errorf("loading catalog %s: %w", file, err)
+		}
+		catalogs[i] = catalog.current()
+	}
+
+	writeAnalyticsReport(w, store.report(catalogs))
+	return nil
+}
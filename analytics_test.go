@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useTestAnalytics replaces the process-wide analytics store until the test ends
+func useTestAnalytics(t *testing.T, store *analyticsStore) {
+	t.Helper()
+	previous := analytics
+	analytics = store
+	t.Cleanup(func() { analytics = previous })
+}
+
+// reportFor returns the report of a single action ID
+func reportFor(t *testing.T, report analyticsReport, actionID string) actionReport {
+	t.Helper()
+	for _, r := range report.Actions {
+		if r.ActionID == actionID {
+			return r
+		}
+	}
+	t.Fatalf("Expected a report for %s, got %+v", actionID, report)
+	return actionReport{}
+}
+
+func TestQueryPrefix(t *testing.T) {
+	tests := []struct {
+		query    string
+		length   int
+		expected string
+	}{
+		{query: "Deploy", length: 10, expected: "deploy"},
+		{query: "  Café   au  Lait ", length: 10, expected: "cafe au la"},
+		{query: "repo ", length: 5, expected: "repo"},
+		{query: "anything", length: 0, expected: ""},
+		{query: "   ", length: 10, expected: ""},
+	}
+
+	for _, tt := range tests {
+		if got := queryPrefix(tt.query, tt.length); got != tt.expected {
+			t.Errorf("queryPrefix(%q, %d): expected %q, got %q", tt.query, tt.length, tt.expected, got)
+		}
+	}
+}
+
+func TestAnalytics_RecordsSearches(t *testing.T) {
+	setupTestCatalogWithMoreOptions()
+	store := newAnalyticsStore("", 10, 1)
+	useTestAnalytics(t, store)
+	responses = newResponseCache(10)
+	defer func() { responses = nil }()
+
+	suggest(t, SlackRequest{ActionID: "test_action", Value: "Slack"})
+	suggest(t, SlackRequest{ActionID: "test_action", Value: "slack"}) // served from the cache
+	suggest(t, SlackRequest{ActionID: "test_action", Value: "Nothing Matches This"})
+	suggest(t, SlackRequest{ActionID: "test_action", Value: "nothing matches this"}) // served from the cache
+	suggest(t, SlackRequest{ActionID: "test_action"})
+	suggest(t, SlackRequest{ActionID: "unknown_action", Value: "slack"})
+
+	r := reportFor(t, store.report(nil), "test_action")
+	if r.Searches != 5 {
+		t.Errorf("Expected 5 searches, got %d", r.Searches)
+	}
+	if r.ZeroResults != 2 {
+		t.Errorf("Expected 2 searches with no results, got %d", r.ZeroResults)
+	}
+	expectedQueries := []analyticsCount{{Key: "nothing ma", Count: 2}, {Key: "slack", Count: 2}}
+	if !reflect.DeepEqual(r.TopQueries, expectedQueries) {
+		t.Errorf("Expected top queries %v, got %v", expectedQueries, r.TopQueries)
+	}
+	expectedZero := []analyticsCount{{Key: "nothing ma", Count: 2}}
+	if !reflect.DeepEqual(r.TopZeroResultQueries, expectedZero) {
+		t.Errorf("Expected zero result queries %v, got %v", expectedZero, r.TopZeroResultQueries)
+	}
+	if len(store.report(nil).Actions) != 1 {
+		t.Errorf("Expected searches of unknown actions not to be recorded, got %+v", store.report(nil).Actions)
+	}
+}
+
+func TestAnalytics_RecordsSelectionsOfCatalogEntries(t *testing.T) {
+	setupTestCatalog()
+	store := newAnalyticsStore("", 10, 1)
+	useTestAnalytics(t, store)
+
+	rr := sendTestRequest(t, "secret", SlackRequest{
+		Type: "block_actions",
+		Actions: []SlackAction{
+			{Type: "external_select", ActionID: "test_action", SelectedOption: &SlackSelectedOption{Value: "opt1"}},
+			{Type: "external_select", ActionID: "other_select", SelectedOption: &SlackSelectedOption{Value: "x"}},
+		},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	report := store.report([]*catalogIndex{currentCatalog()})
+	r := reportFor(t, report, "test_action")
+	if r.Selections != 1 {
+		t.Errorf("Expected 1 selection, got %d", r.Selections)
+	}
+	if !reflect.DeepEqual(r.DeadOptions, []string{"opt2"}) || r.DeadOptionCount != 1 {
+		t.Errorf("Expected opt2 never to be selected, got %v (%d)", r.DeadOptions, r.DeadOptionCount)
+	}
+	if len(report.Actions) != 1 {
+		t.Errorf("Expected selections outside the catalog not to be recorded, got %+v", report.Actions)
+	}
+}
+
+func TestAnalyticsStore_Report(t *testing.T) {
+	store := newAnalyticsStore("", 10, 2)
+	for _, query := range []string{"deploy", "deploy", "deploy", "repo", "repo", "rare"} {
+		store.recordSearch("repos", query, 1)
+	}
+	store.recordSelection("repos", "b")
+	store.recordSelection("repos", "b")
+	store.recordSelection("repos", "a")
+
+	catalog := newCatalogIndex([]CatalogEntry{
+		{ActionID: "repos", Options: []Option{{Value: "a"}, {Value: "b"}, {Value: "c"}}},
+		{ActionID: "repos", BlockID: "other", Options: []Option{{Value: "c"}, {Value: "d"}}},
+		{ActionID: "envs", OptionsByValue: map[string][]Option{"x": {{Value: "prod"}}, "y": {{Value: "prod"}, {Value: "dev"}}}},
+	})
+	report := store.report([]*catalogIndex{catalog})
+
+	if len(report.Actions) != 2 || report.Actions[0].ActionID != "envs" || report.Actions[1].ActionID != "repos" {
+		t.Fatalf("Expected reports for envs and repos, got %+v", report.Actions)
+	}
+
+	repos := report.Actions[1]
+	expectedQueries := []analyticsCount{{Key: "deploy", Count: 3}, {Key: "repo", Count: 2}}
+	if !reflect.DeepEqual(repos.TopQueries, expectedQueries) {
+		t.Errorf("Expected rare queries to be hidden, got %v", repos.TopQueries)
+	}
+	expectedSelections := []analyticsCount{{Key: "b", Count: 2}, {Key: "a", Count: 1}}
+	if !reflect.DeepEqual(repos.TopSelections, expectedSelections) {
+		t.Errorf("Expected top selections %v, got %v", expectedSelections, repos.TopSelections)
+	}
+	if !reflect.DeepEqual(repos.DeadOptions, []string{"c", "d"}) {
+		t.Errorf("Expected dead options [c d], got %v", repos.DeadOptions)
+	}
+
+	envs := report.Actions[0]
+	if envs.Searches != 0 || !reflect.DeepEqual(envs.DeadOptions, []string{"prod", "dev"}) {
+		t.Errorf("Expected an unsearched action with dead options [prod dev], got %+v", envs)
+	}
+}
+
+func TestAnalyticsStore_BoundsDistinctKeys(t *testing.T) {
+	store := newAnalyticsStore("", 10, 1)
+	for i := range maxAnalyticsKeys + 10 {
+		store.recordSelection("repos", strings.Repeat("x", i+1))
+	}
+	store.recordSelection("repos", "x")
+
+	counts := store.actions["repos"].Selections
+	if len(counts) != maxAnalyticsKeys {
+		t.Errorf("Expected %d distinct values, got %d", maxAnalyticsKeys, len(counts))
+	}
+	if counts["x"] != 2 {
+		t.Errorf("Expected known values to keep counting, got %d", counts["x"])
+	}
+}
+
+func TestAnalyticsStore_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analytics.json")
+	store := newAnalyticsStore(path, 10, 1)
+	store.recordSearch("repos", "deploy", 0)
+	store.recordSelection("repos", "a")
+	if err := store.save(); err != nil {
+		t.Fatalf("Failed to save analytics: %v", err)
+	}
+
+	loaded := newAnalyticsStore(path, 10, 1)
+	if err := loaded.load(); err != nil {
+		t.Fatalf("Failed to load analytics: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.report(nil), store.report(nil)) {
+		t.Errorf("Expected %+v, got %+v", store.report(nil), loaded.report(nil))
+	}
+
+	// Unchanged analytics are not written again
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Failed to remove analytics file: %v", err)
+	}
+	if err := loaded.save(); err != nil {
+		t.Fatalf("Failed to save analytics: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected unchanged analytics not to be saved, got %v", err)
+	}
+
+	if err := newAnalyticsStore(filepath.Join(t.TempDir(), "missing.json"), 10, 1).load(); err != nil {
+		t.Errorf("Expected a missing file to be ignored, got %v", err)
+	}
+}
+
+func TestHandleAnalyticsReport(t *testing.T) {
+	store := newAnalyticsStore("", 10, 1)
+	store.recordSearch("test_action", "opt", 2)
+	useTestAnalytics(t, store)
+
+	catalog := &catalogStore{}
+	if err := catalog.set([]CatalogEntry{{ActionID: "test_action", Options: []Option{{Text: "Option 1", Value: "opt1"}}}}); err != nil {
+		t.Fatalf("Failed to set catalog: %v", err)
+	}
+	apps := []*slackApp{{config: AppConfig{Name: defaultAppName}, catalog: catalog}}
+
+	rr := httptest.NewRecorder()
+	newAdminMux(apps).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, analyticsPath, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var report analyticsReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	r := reportFor(t, report, "test_action")
+	if r.Searches != 1 || !reflect.DeepEqual(r.DeadOptions, []string{"opt1"}) {
+		t.Errorf("Expected 1 search and dead option opt1, got %+v", r)
+	}
+
+	// Analytics are only recorded and reported when enabled
+	useTestAnalytics(t, nil)
+	rr = httptest.NewRecorder()
+	newAdminMux(apps).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, analyticsPath, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 with analytics disabled, got %d", rr.Code)
+	}
+}
+
+func TestRunAnalyticsReport(t *testing.T) {
+	dir := t.TempDir()
+	catalogFile := writeTestCatalog(t, `[{"actionId": "repos", "options": [{"text": "A", "value": "a"}, {"text": "B", "value": "b"}]}]`)
+	analyticsFile := filepath.Join(dir, "analytics.json")
+	store := newAnalyticsStore(analyticsFile, 10, 1)
+	for range 3 {
+		store.recordSearch("repos", "deploy", 0)
+	}
+	store.recordSearch("repos", "rare", 1)
+	store.recordSelection("repos", "a")
+	if err := store.save(); err != nil {
+		t.Fatalf("Failed to save analytics: %v", err)
+	}
+
+	config, err := loadConfig([]string{"--report", "--config-file", catalogFile, "--analytics-file", analyticsFile}, fakeEnv(nil))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runAnalyticsReport(&out, config); err != nil {
+		t.Fatalf("Failed to report analytics: %v", err)
+	}
+	expected := `repos: 4 search(es), 3 with no results, 1 selection(s)
+  Top queries: "deploy" (3)
+  No results: "deploy" (3)
+  Most selected: "a" (1)
+  Never selected (1): b
+`
+	if out.String() != expected {
+		t.Errorf("Expected report:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	config.AnalyticsFile = ""
+	if err := runAnalyticsReport(&out, config); err == nil || !strings.Contains(err.Error(), "ANALYTICS_FILE is required") {
+		t.Errorf("Expected an error without ANALYTICS_FILE, got %v", err)
+	}
+}
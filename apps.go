@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	// defaultAppName names the app configured by the top-level settings
+	defaultAppName = "default"
+	// debugVarsPath is the admin endpoint exposing expvar metrics
+	debugVarsPath = "/debug/vars"
+)
+
+// AppConfig configures a Slack app served alongside the default one, with its
+// own catalog, signing secret and endpoint paths
+type AppConfig struct {
+	Name                   string `json:"name"`
+	ConfigFile             string `json:"config-file"`
+	SlackSigningSecret     string `json:"slack-signing-secret,omitempty"`
+	SlackSigningSecretFile string `json:"slack-signing-secret-file,omitempty"`
+	OptionsPath            string `json:"options-path,omitempty"`
+	InteractivePath        string `json:"interactive-path,omitempty"`
+	CommandsPath           string `json:"commands-path,omitempty"`
+}
+
+// paths returns the endpoint paths an app is mounted on, skipping unset ones
+func (a AppConfig) paths() []string {
+	var paths []string
+	for _, path := range []string{a.OptionsPath, a.InteractivePath, a.CommandsPath} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// servesDefaultApp reports whether the top-level settings configure an app,
+// over HTTP, Socket Mode or both
+func (c Config) servesDefaultApp() bool {
+	return c.SlackSigningSecret != "" || c.SlackSigningSecretFile != "" ||
+		c.SlackAppToken != "" || c.SlackAppTokenFile != ""
+}
+
+// defaultApp returns the configuration of the app set by the top-level settings
+func (c Config) defaultApp() AppConfig {
+	return AppConfig{
+		Name:                   defaultAppName,
+		ConfigFile:             c.ConfigFile,
+		SlackSigningSecret:     c.SlackSigningSecret,
+		SlackSigningSecretFile: c.SlackSigningSecretFile,
+		OptionsPath:            c.OptionsPath,
+		InteractivePath:        c.InteractivePath,
+		CommandsPath:           c.CommandsPath,
+	}
+}
+
+// validateApps checks the additional apps and that no two apps share an
+// endpoint path
+func validateApps(c Config) error {
+	var errs []error
+	owners := map[string]string{debugVarsPath: "admin endpoints", analyticsPath: "admin endpoints"}
+	claim := func(app AppConfig) {
+		for _, path := range app.paths() {
+			if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, " {}") {
+				errs = append(errs, fmt.Errorf("app '%s': path %q must start with '/' and contain no spaces or braces", app.Name, path))
+				continue
+			}
+			if owner, ok := owners[path]; ok {
+				errs = append(errs, fmt.Errorf("app '%s': path %s is already used by %s", app.Name, path, owner))
+				continue
+			}
+			owners[path] = "app '" + app.Name + "'"
+		}
+	}
+
+	if c.SlackSigningSecret != "" || c.SlackSigningSecretFile != "" {
+		claim(c.defaultApp())
+	}
+
+	names := map[string]bool{defaultAppName: true}
+	for i, app := range c.Apps {
+		switch {
+		case app.Name == "":
+			errs = append(errs, fmt.Errorf("app %d: name is required", i))
+			continue
+		case names[app.Name]:
+			errs = append(errs, fmt.Errorf("app %d: name '%s' is already used", i, app.Name))
+			continue
+		}
+		names[app.Name] = true
+
+		if app.ConfigFile == "" {
+			errs = append(errs, fmt.Errorf("app '%s': config-file is required", app.Name))
+		}
+		if (app.SlackSigningSecret == "") == (app.SlackSigningSecretFile == "") {
+			errs = append(errs, fmt.Errorf("app '%s': exactly one of slack-signing-secret and slack-signing-secret-file is required", app.Name))
+		}
+		if len(app.paths()) == 0 {
+			errs = append(errs, fmt.Errorf("app '%s': at least one of options-path, interactive-path and commands-path is required", app.Name))
+		}
+		claim(app)
+	}
+	return errors.Join(errs...)
+}
+
+// slackApp is a Slack app served by the process: a catalog, the secret
+// verifying its requests and the paths it is mounted on
+type slackApp struct {
+	config        AppConfig
+	catalog       *catalogStore
+	signingSecret secretSource // nil if the app is only served over Socket Mode
+}
+
+// newSlackApps builds the default app, if configured, followed by the
+// additional apps. The default app serves defaultCatalog.
+func newSlackApps(c Config) ([]*slackApp, error) {
+	var apps []*slackApp
+	if c.servesDefaultApp() {
+		signingSecret, err := newSecretSource(c.SlackSigningSecret, c.SlackSigningSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("app '%s': loading the signing secret: %w", defaultAppName, err)
+		}
+		apps = append(apps, &slackApp{config: c.defaultApp(), catalog: defaultCatalog, signingSecret: signingSecret})
+	}
+
+	for _, appConfig := range c.Apps {
+		signingSecret, err := newSecretSource(appConfig.SlackSigningSecret, appConfig.SlackSigningSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("app '%s': loading the signing secret: %w", appConfig.Name, err)
+		}
+		apps = append(apps, &slackApp{config: appConfig, catalog: &catalogStore{}, signingSecret: signingSecret})
+	}
+	return apps, nil
+}
+
+// mount registers the app's handlers on its endpoint paths. Every
+// interaction path dispatches all payload types, so Slack's options load URL
+// and request URL may point to the same path or to different ones.
+func (a *slackApp) mount(mux *http.ServeMux) {
+	if a.signingSecret == nil {
+		return
+	}
+
+	interactions := traceHandler("slack.interaction", limitByIP(handleRequest(a.catalog, a.signingSecret)))
+	for _, path := range []string{a.config.OptionsPath, a.config.InteractivePath} {
+		if path != "" {
+			log.Printf("Serving interactions for app '%s' on %s", a.config.Name, path)
+			mux.Handle(path, interactions)
+		}
+	}
+	if a.config.CommandsPath != "" {
+		log.Printf("Serving slash commands for app '%s' on %s", a.config.Name, a.config.CommandsPath)
+		mux.Handle(a.config.CommandsPath, traceHandler("slack.command", limitByIP(handleSlashCommand(a.catalog, a.signingSecret))))
+	}
+}
+
+// servesHTTP reports whether any app is served over HTTP
+func servesHTTP(apps []*slackApp) bool {
+	for _, app := range apps {
+		if app.signingSecret != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// debugVarsHandler serves the published expvar variables like
+// expvar.Handler, leaving out the command line as it may hold settings
+func debugVarsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, "{\n")
+		first := true
+		expvar.Do(func(kv expvar.KeyValue) {
+			if kv.Key == "cmdline" {
+				return
+			}
+			if !first {
+				fmt.Fprintf(w, ",\n")
+			}
+			first = false
+			fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+		})
+		fmt.Fprintf(w, "\n}\n")
+	})
+}
+
+// newAdminMux serves the admin endpoints
+func newAdminMux(apps []*slackApp) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle(debugVarsPath, debugVarsHandler())
+	mux.Handle(analyticsPath, handleAnalyticsReport(apps))
+	return mux
+}
+
+// mainListenerAdmin returns the admin endpoints to serve alongside the apps,
+// or nil. They are denied by default: only a client CA restricting them to
+// clients with a certificate puts them on the main listener, and with an
+// admin listen address they are served there instead.
+func mainListenerAdmin(c Config, admin http.Handler) http.Handler {
+	switch {
+	case c.AdminListenAddress != "":
+		return nil
+	case c.TLSClientCAFile != "":
+		return requireClientCert(true, admin)
+	default:
+		log.Printf("Admin endpoints are disabled; set ADMIN_LISTEN_ADDRESS or TLS_CLIENT_CA_FILE to serve them")
+		return nil
+	}
+}
+
+// serveAdmin serves the admin endpoints on their own listener, over TLS when
+// it is configured
+func serveAdmin(address string, admin http.Handler, tlsConfig *tls.Config) {
+	listener, err := listenOn(address)
+	if err != nil {
+		log.Fatalf("Failed to listen for admin endpoints: %v", err)
+	}
+
+	server := &http.Server{Handler: admin, TLSConfig: tlsConfig}
+	log.Printf("Serving admin endpoints on %s", listener.Addr())
+	if tlsConfig != nil {
+		err = server.ServeTLS(listener, "", "")
+	} else {
+		err = server.Serve(listener)
+	}
+	log.Fatalf("Admin server failed: %v", err)
+}
+
+// newServeMux mounts every app and, unless admin is nil, the admin endpoints
+func newServeMux(apps []*slackApp, admin http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, app := range apps {
+		app.mount(mux)
+	}
+	if admin != nil {
+		mux.Handle(debugVarsPath, admin)
+		mux.Handle(analyticsPath, admin)
+	}
+	return mux
+}
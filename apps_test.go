@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sendSignedRequestTo signs and sends a body to a path of the given handler and returns the recorded response
+func sendSignedRequestTo(t *testing.T, handler http.Handler, path, contentType, secret string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", generateTestSignature(secret, timestamp, body))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestLoadConfig_Apps(t *testing.T) {
+	settingsFile := writeSettingsFile(t, `{
+		"apps": [
+			{
+				"name": "ops",
+				"config-file": "ops.json",
+				"slack-signing-secret-file": "/run/secrets/ops",
+				"options-path": "/ops/options",
+				"interactive-path": "/ops/interactive"
+			}
+		]
+	}`)
+	env := map[string]string{
+		"SETTINGS_FILE":        settingsFile,
+		"SLACK_SIGNING_SECRET": "secret",
+		"OPTIONS_PATH":         "/slack/options",
+	}
+
+	config, err := loadConfig([]string{"--commands-path", "/slack/commands"}, fakeEnv(env))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if config.OptionsPath != "/slack/options" {
+		t.Errorf("Expected options path /slack/options, got %s", config.OptionsPath)
+	}
+	if config.InteractivePath != "" {
+		t.Errorf("Expected no interactive path, got %s", config.InteractivePath)
+	}
+	if config.CommandsPath != "/slack/commands" {
+		t.Errorf("Expected commands path /slack/commands, got %s", config.CommandsPath)
+	}
+	if len(config.Apps) != 1 {
+		t.Fatalf("Expected 1 app, got %d", len(config.Apps))
+	}
+	expected := AppConfig{
+		Name:                   "ops",
+		ConfigFile:             "ops.json",
+		SlackSigningSecretFile: "/run/secrets/ops",
+		OptionsPath:            "/ops/options",
+		InteractivePath:        "/ops/interactive",
+	}
+	if config.Apps[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, config.Apps[0])
+	}
+}
+
+func TestLoadConfig_AppsWithoutDefaultApp(t *testing.T) {
+	settingsFile := writeSettingsFile(t, `{"apps": [{"name": "ops", "config-file": "ops.json", "slack-signing-secret": "s", "options-path": "/ops"}]}`)
+
+	config, err := loadConfig([]string{"--settings", settingsFile}, fakeEnv(nil))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.servesDefaultApp() {
+		t.Error("Expected the default app not to be served")
+	}
+}
+
+func TestValidateApps(t *testing.T) {
+	app := func(name, path string) AppConfig {
+		return AppConfig{Name: name, ConfigFile: name + ".json", SlackSigningSecret: "secret", OptionsPath: path}
+	}
+
+	tests := []struct {
+		name    string
+		config  Config
+		errText string
+	}{
+		{
+			name:   "distinct paths",
+			config: Config{SlackSigningSecret: "s", OptionsPath: "/", CommandsPath: "/commands", Apps: []AppConfig{app("a", "/a"), app("b", "/b")}},
+		},
+		{
+			name:   "socket mode default app claims no paths",
+			config: Config{SlackAppToken: "xapp", OptionsPath: "/a", Apps: []AppConfig{app("a", "/a")}},
+		},
+		{
+			name:    "path shared with the default app",
+			config:  Config{SlackSigningSecret: "s", OptionsPath: "/a", Apps: []AppConfig{app("a", "/a")}},
+			errText: "path /a is already used by app 'default'",
+		},
+		{
+			name:    "path shared between apps",
+			config:  Config{Apps: []AppConfig{app("a", "/shared"), app("b", "/shared")}},
+			errText: "app 'b': path /shared is already used by app 'a'",
+		},
+		{
+			name:    "admin path",
+			config:  Config{Apps: []AppConfig{app("a", "/debug/vars")}},
+			errText: "already used by admin endpoints",
+		},
+		{
+			name:    "relative path",
+			config:  Config{Apps: []AppConfig{app("a", "slack")}},
+			errText: "must start with '/'",
+		},
+		{
+			name:    "duplicate name",
+			config:  Config{Apps: []AppConfig{app("a", "/a"), app("a", "/b")}},
+			errText: "name 'a' is already used",
+		},
+		{
+			name:    "reserved name",
+			config:  Config{Apps: []AppConfig{app("default", "/a")}},
+			errText: "name 'default' is already used",
+		},
+		{
+			name:    "missing name",
+			config:  Config{Apps: []AppConfig{app("", "/a")}},
+			errText: "app 0: name is required",
+		},
+		{
+			name:    "missing config file",
+			config:  Config{Apps: []AppConfig{{Name: "a", SlackSigningSecret: "s", OptionsPath: "/a"}}},
+			errText: "config-file is required",
+		},
+		{
+			name:    "missing secret",
+			config:  Config{Apps: []AppConfig{{Name: "a", ConfigFile: "a.json", OptionsPath: "/a"}}},
+			errText: "exactly one of slack-signing-secret and slack-signing-secret-file",
+		},
+		{
+			name:    "two secrets",
+			config:  Config{Apps: []AppConfig{{Name: "a", ConfigFile: "a.json", SlackSigningSecret: "s", SlackSigningSecretFile: "f", OptionsPath: "/a"}}},
+			errText: "exactly one of slack-signing-secret and slack-signing-secret-file",
+		},
+		{
+			name:    "no paths",
+			config:  Config{Apps: []AppConfig{{Name: "a", ConfigFile: "a.json", SlackSigningSecret: "s"}}},
+			errText: "at least one of options-path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateApps(tt.config)
+			if tt.errText == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+}
+
+func TestServeMux_AppsServeOwnCatalogs(t *testing.T) {
+	mainCatalog := &catalogStore{}
+	if err := mainCatalog.set([]CatalogEntry{{ActionID: "env", Options: []Option{{Text: "Production", Value: "prod"}}}}); err != nil {
+		t.Fatalf("Failed to set catalog: %v", err)
+	}
+	opsCatalog := &catalogStore{}
+	if err := opsCatalog.set([]CatalogEntry{{ActionID: "env", Options: []Option{{Text: "Staging", Value: "staging"}}}}); err != nil {
+		t.Fatalf("Failed to set catalog: %v", err)
+	}
+
+	apps := []*slackApp{
+		{
+			config:        AppConfig{Name: defaultAppName, OptionsPath: "/slack/options", CommandsPath: "/slack/commands"},
+			catalog:       mainCatalog,
+			signingSecret: staticSecret("main-secret"),
+		},
+		{
+			config:        AppConfig{Name: "ops", OptionsPath: "/ops/options", InteractivePath: "/ops/interactive", CommandsPath: "/ops/commands"},
+			catalog:       opsCatalog,
+			signingSecret: staticSecret("ops-secret"),
+		},
+	}
+	mux := newServeMux(apps, nil)
+
+	suggestion, err := json.Marshal(SlackRequest{Type: "block_suggestion", ActionID: "env"})
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %v", err)
+	}
+	command := []byte(url.Values{"command": {"/catalog"}, "text": {"env"}}.Encode())
+
+	tests := []struct {
+		name        string
+		path        string
+		secret      string
+		contentType string
+		body        []byte
+		status      int
+		contains    string
+	}{
+		{name: "default app options", path: "/slack/options", secret: "main-secret", contentType: "application/json", body: suggestion, status: http.StatusOK, contains: "prod"},
+		{name: "default app commands", path: "/slack/commands", secret: "main-secret", contentType: "application/x-www-form-urlencoded", body: command, status: http.StatusOK, contains: "prod"},
+		{name: "ops app options", path: "/ops/options", secret: "ops-secret", contentType: "application/json", body: suggestion, status: http.StatusOK, contains: "staging"},
+		{name: "ops app interactive", path: "/ops/interactive", secret: "ops-secret", contentType: "application/json", body: suggestion, status: http.StatusOK, contains: "staging"},
+		{name: "ops app commands", path: "/ops/commands", secret: "ops-secret", contentType: "application/x-www-form-urlencoded", body: command, status: http.StatusOK, contains: "staging"},
+		{name: "other app's secret", path: "/ops/options", secret: "main-secret", contentType: "application/json", body: suggestion, status: http.StatusUnauthorized},
+		{name: "unmounted path", path: "/", secret: "main-secret", contentType: "application/json", body: suggestion, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := sendSignedRequestTo(t, mux, tt.path, tt.contentType, tt.secret, tt.body)
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, rr.Code)
+			}
+			if !strings.Contains(rr.Body.String(), tt.contains) {
+				t.Errorf("Expected response to contain %q, got %s", tt.contains, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestDebugVarsHandler_OmitsCommandLine(t *testing.T) {
+	rr := httptest.NewRecorder()
+	debugVarsHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, debugVarsPath, nil))
+
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(rr.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("Failed to decode variables: %v\n%s", err, rr.Body.String())
+	}
+	if _, ok := vars["cmdline"]; ok {
+		t.Error("Expected the command line not to be published")
+	}
+	if _, ok := vars["responseCache"]; !ok {
+		t.Errorf("Expected the responseCache variables, got %v", vars)
+	}
+}
+
+func TestMainListenerAdmin(t *testing.T) {
+	apps := []*slackApp{{config: AppConfig{Name: defaultAppName, OptionsPath: "/slack/options"}, catalog: &catalogStore{}, signingSecret: staticSecret("secret")}}
+	admin := newAdminMux(apps)
+
+	tests := []struct {
+		name   string
+		config Config
+		status int
+	}{
+		{name: "denied by default", config: Config{}, status: http.StatusNotFound},
+		{name: "separate admin listener", config: Config{AdminListenAddress: "127.0.0.1:9090"}, status: http.StatusNotFound},
+		{name: "client CA", config: Config{TLSClientCAFile: "client-ca.pem"}, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newServeMux(apps, mainListenerAdmin(tt.config, admin))
+			for _, path := range []string{debugVarsPath, analyticsPath} {
+				rr := httptest.NewRecorder()
+				mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+				if rr.Code != tt.status {
+					t.Errorf("%s: expected status %d, got %d", path, tt.status, rr.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestNewServeMux_SocketModeOnlyAppIsNotMounted(t *testing.T) {
+	apps := []*slackApp{{config: AppConfig{Name: defaultAppName, OptionsPath: "/"}, catalog: &catalogStore{}}}
+	if servesHTTP(apps) {
+		t.Error("Expected an app without a signing secret not to be served over HTTP")
+	}
+
+	rr := httptest.NewRecorder()
+	newServeMux(apps, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestPrintConfig_ListsApps(t *testing.T) {
+	config := defaultConfig()
+	config.Apps = []AppConfig{{Name: "ops", ConfigFile: "ops.json", SlackSigningSecret: "ops-secret", OptionsPath: "/ops"}}
+
+	var out bytes.Buffer
+	printConfig(&out, config)
+	printed := out.String()
+
+	if strings.Contains(printed, "ops-secret") {
+		t.Errorf("Expected the app's signing secret to be masked, got:\n%s", printed)
+	}
+	line := "APP=ops CONFIG_FILE=ops.json SLACK_SIGNING_SECRET=******** SLACK_SIGNING_SECRET_FILE= OPTIONS_PATH=/ops INTERACTIVE_PATH= COMMANDS_PATH= (settings file)\n"
+	if !strings.Contains(printed, line) {
+		t.Errorf("Expected output to contain %q, got:\n%s", line, printed)
+	}
+}
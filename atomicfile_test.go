@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	for _, contents := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, ".state-*.json", []byte(contents)); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(data) != contents {
+			t.Errorf("Expected %q, got %q", contents, data)
+		}
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to list directory: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Expected the temporary file to be removed, got %d file(s)", len(files))
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "state.json"), ".state-*.json", nil); err == nil {
+		t.Error("Expected an error writing to a missing directory")
+	}
+}
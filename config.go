@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Configuration layers, from lowest to highest precedence
+const (
+	sourceDefault  = "default"
+	sourceSettings = "settings file"
+	sourceEnv      = "environment"
+	sourceFlag     = "flag"
+)
+
+// setting describes a single configuration value, read from the settings
+// file and flags under its flag name and from the environment under env.
+// Secret settings have no flag.
+type setting struct {
+	env    string
+	usage  string
+	secret bool // masked by --print-config
+	isBool bool // set without a value on the command line
+	set    func(c *Config, value string) error
+	get    func(c Config) string
+}
+
+// flagName returns the command-line flag and settings file key of a setting,
+// e.g. "rate-limit-ip-rps" for RATE_LIMIT_IP_RPS
+func (s setting) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(s.env), "_", "-")
+}
+
+// stringSetting describes a setting held in a string field
+func stringSetting(env, usage string, field func(*Config) *string) setting {
+	return setting{
+		env:   env,
+		usage: usage,
+		set:   func(c *Config, value string) error { *field(c) = value; return nil },
+		get:   func(c Config) string { return *field(&c) },
+	}
+}
+
+// secretSetting describes a string setting whose value is masked when printed
+func secretSetting(env, usage string, field func(*Config) *string) setting {
+	s := stringSetting(env, usage, field)
+	s.secret = true
+	return s
+}
+
+// intSetting describes a setting held in a non-negative int field
+func intSetting(env, usage string, field func(*Config) *int) setting {
+	return setting{
+		env:   env,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("must be a non-negative integer, got %q", value)
+			}
+			*field(c) = n
+			return nil
+		},
+		get: func(c Config) string { return strconv.Itoa(*field(&c)) },
+	}
+}
+
+// floatSetting describes a setting held in a non-negative float64 field
+func floatSetting(env, usage string, field func(*Config) *float64) setting {
+	return setting{
+		env:   env,
+		usage: usage,
+		set: func(c *Config, value string) error {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+				return fmt.Errorf("must be a non-negative number, got %q", value)
+			}
+			*field(c) = f
+			return nil
+		},
+		get: func(c Config) string { return strconv.FormatFloat(*field(&c), 'g', -1, 64) },
+	}
+}
+
+// boolSetting describes a setting held in a bool field
+func boolSetting(env, usage string, field func(*Config) *bool) setting {
+	return setting{
+		env:    env,
+		usage:  usage,
+		isBool: true,
+		set: func(c *Config, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("must be true or false, got %q", value)
+			}
+			*field(c) = b
+			return nil
+		},
+		get: func(c Config) string { return strconv.FormatBool(*field(&c)) },
+	}
+}
+
+// settings lists every configuration value, in the order --print-config shows them
+var settings = []setting{
+	stringSetting("PORT", "port to run the server on", func(c *Config) *string { return &c.Port }),
+	stringSetting("LISTEN_ADDRESS", "address to listen on, e.g. 127.0.0.1:8080 or unix:/run/octocatalog.sock (overrides PORT)", func(c *Config) *string { return &c.ListenAddress }),
+	secretSetting("SLACK_SIGNING_SECRET", "Slack signing secret for request validation", func(c *Config) *string { return &c.SlackSigningSecret }),
+	stringSetting("SLACK_SIGNING_SECRET_FILE", "file holding the signing secret, reread when it changes", func(c *Config) *string { return &c.SlackSigningSecretFile }),
+	stringSetting("CONFIG_FILE", "path to the catalog file", func(c *Config) *string { return &c.ConfigFile }),
+	stringSetting("SELECTIONS_FILE", "path where selection history is persisted", func(c *Config) *string { return &c.SelectionsFile }),
+	secretSetting("SLACK_APP_TOKEN", "app-level token enabling Socket Mode", func(c *Config) *string { return &c.SlackAppToken }),
+	stringSetting("SLACK_APP_TOKEN_FILE", "file holding the app-level token, reread when it changes", func(c *Config) *string { return &c.SlackAppTokenFile }),
+	stringSetting("SLACK_SOCKET_MODE_URL", "WebSocket URL overriding the one requested from Slack", func(c *Config) *string { return &c.SocketModeURL }),
+	intSetting("RESPONSE_CACHE_SIZE", "number of suggestion responses to cache (0 disables)", func(c *Config) *int { return &c.ResponseCacheSize }),
+	floatSetting("RATE_LIMIT_IP_RPS", "requests per second allowed per client IP (0 is unlimited)", func(c *Config) *float64 { return &c.IPRateLimit }),
+	intSetting("RATE_LIMIT_IP_BURST", "burst allowed per client IP", func(c *Config) *int { return &c.IPRateBurst }),
+	floatSetting("RATE_LIMIT_TEAM_RPS", "requests per second allowed per Slack team (0 is unlimited)", func(c *Config) *float64 { return &c.TeamRateLimit }),
+	intSetting("RATE_LIMIT_TEAM_BURST", "burst allowed per Slack team", func(c *Config) *int { return &c.TeamRateBurst }),
+	boolSetting("RATE_LIMIT_TRUST_FORWARDED_FOR", "take the client IP from X-Forwarded-For", func(c *Config) *bool { return &c.TrustForwardedFor }),
+	stringSetting("TLS_CERT_FILE", "PEM certificate file to serve HTTPS", func(c *Config) *string { return &c.TLSCertFile }),
+	stringSetting("TLS_KEY_FILE", "PEM key file to serve HTTPS", func(c *Config) *string { return &c.TLSKeyFile }),
+	stringSetting("TLS_MIN_VERSION", "minimum TLS version: 1.0, 1.1, 1.2 or 1.3", func(c *Config) *string { return &c.TLSMinVersion }),
+	stringSetting("TLS_CLIENT_CA_FILE", "PEM CA bundle for admin client certificates", func(c *Config) *string { return &c.TLSClientCAFile }),
+	stringSetting("ADMIN_LISTEN_ADDRESS", "separate address serving the admin endpoints, e.g. 127.0.0.1:9090", func(c *Config) *string { return &c.AdminListenAddress }),
+	stringSetting("OPTIONS_PATH", "path receiving option load requests", func(c *Config) *string { return &c.OptionsPath }),
+	stringSetting("INTERACTIVE_PATH", "path receiving other interactions (defaults to the options path)", func(c *Config) *string { return &c.InteractivePath }),
+	stringSetting("COMMANDS_PATH", "path receiving slash commands", func(c *Config) *string { return &c.CommandsPath }),
+	stringSetting("TRACING_ENDPOINT", "OTLP/HTTP endpoint to export traces to, e.g. http://localhost:4318 (empty disables tracing)", func(c *Config) *string { return &c.TracingEndpoint }),
+	floatSetting("TRACING_SAMPLE_RATIO", "fraction of traces to sample, from 0 to 1", func(c *Config) *float64 { return &c.TracingSampleRatio }),
+	boolSetting("ANALYTICS", "record anonymous search analytics", func(c *Config) *bool { return &c.Analytics }),
+	stringSetting("ANALYTICS_FILE", "path where search analytics are persisted", func(c *Config) *string { return &c.AnalyticsFile }),
+	intSetting("ANALYTICS_PREFIX_LENGTH", "characters of each query recorded by the analytics (0 records none)", func(c *Config) *int { return &c.AnalyticsPrefixLength }),
+	intSetting("ANALYTICS_MIN_COUNT", "searches needed for a query to appear in analytics reports", func(c *Config) *int { return &c.AnalyticsMinCount }),
+}
+
+// defaultConfig returns the configuration used when nothing else is set
+func defaultConfig() Config {
+	return Config{
+		Port:                  "8080",
+		ConfigFile:            "catalog.json",
+		ResponseCacheSize:     defaultResponseCacheSize,
+		TLSMinVersion:         "1.2",
+		OptionsPath:           "/",
+		CommandsPath:          "/commands",
+		TracingSampleRatio:    1,
+		AnalyticsPrefixLength: defaultAnalyticsPrefixLength,
+		AnalyticsMinCount:     defaultAnalyticsMinCount,
+	}
+}
+
+// loadConfig builds the configuration from, in increasing order of
+// precedence: defaults, the settings file, environment variables and
+// command-line flags. The settings file is named by --settings or
+// SETTINGS_FILE. lookupEnv is usually os.LookupEnv.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	config := defaultConfig()
+	config.sources = make(map[string]string, len(settings))
+	for _, s := range settings {
+		config.sources[s.env] = sourceDefault
+	}
+
+	// Flags are parsed first to find the settings file, but applied last
+	fs := flag.NewFlagSet("octocatalog", flag.ContinueOnError)
+	var settingsFile string
+	fs.StringVar(&settingsFile, "settings", "", "path to a JSON settings file (env: SETTINGS_FILE)")
+	fs.BoolVar(&config.PrintConfig, "print-config", false, "print the effective configuration, with secrets masked, and exit")
+	fs.BoolVar(&config.Report, "report", false, "print the top queries and never selected options recorded in ANALYTICS_FILE, and exit")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		if s.secret {
+			// The command line is visible to other processes, so secrets are
+			// only read from the environment, the settings file or a _FILE setting
+			continue
+		}
+		usage := fmt.Sprintf("%s (env: %s)", s.usage, s.env)
+		record := func(value string) error {
+			flagValues[s.env] = value
+			return nil
+		}
+		if s.isBool {
+			fs.BoolFunc(s.flagName(), usage, record)
+		} else {
+			fs.Func(s.flagName(), usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if settingsFile == "" {
+		settingsFile, _ = lookupEnv("SETTINGS_FILE")
+	}
+	if settingsFile != "" {
+		values, apps, err := readSettingsFile(settingsFile)
+		if err != nil {
+			return Config{}, err
+		}
+		config.Apps = apps
+		if err := config.apply(values, sourceSettings); err != nil {
+			return Config{}, fmt.Errorf("settings file %s: %w", settingsFile, err)
+		}
+	}
+
+	envValues := make(map[string]string)
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok && value != "" {
+			envValues[s.env] = value
+		}
+	}
+	if err := config.apply(envValues, sourceEnv); err != nil {
+		return Config{}, err
+	}
+
+	if err := config.apply(flagValues, sourceFlag); err != nil {
+		return Config{}, err
+	}
+
+	for _, s := range settings {
+		if s.secret && s.get(config) != "" && config.sources[s.env+"_FILE"] != sourceDefault {
+			return Config{}, fmt.Errorf("only one of %s and %s_FILE may be set", s.env, s.env)
+		}
+	}
+	if !config.servesDefaultApp() && len(config.Apps) == 0 && !config.PrintConfig && !config.Report {
+		return Config{}, errors.New("SLACK_SIGNING_SECRET or SLACK_APP_TOKEN is required, directly or through a _FILE setting")
+	}
+	if err := validateApps(config); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// apply sets the settings in values, keyed by environment variable name, and
+// records the layer they came from
+func (c *Config) apply(values map[string]string, source string) error {
+	for _, s := range settings {
+		value, ok := values[s.env]
+		if !ok {
+			continue
+		}
+		if err := s.set(c, value); err != nil {
+			name := s.env
+			if source != sourceEnv {
+				name = s.flagName()
+			}
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		c.sources[s.env] = source
+	}
+	return nil
+}
+
+// readSettingsFile reads a JSON object of settings keyed by flag name, e.g.
+// {"port": 8080, "tls-min-version": "1.3"}, and returns their values keyed
+// by environment variable name along with the additional apps listed under "apps"
+func readSettingsFile(path string) (map[string]string, []AppConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading settings file: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("parsing settings file %s: %w", path, err)
+	}
+
+	var apps []AppConfig
+	if rawApps, ok := raw["apps"]; ok {
+		decoder := json.NewDecoder(bytes.NewReader(rawApps))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&apps); err != nil {
+			return nil, nil, fmt.Errorf("settings file %s: apps: %w", path, err)
+		}
+		delete(raw, "apps")
+	}
+
+	byFlag := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byFlag[s.flagName()] = s
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, rawValue := range raw {
+		s, ok := byFlag[key]
+		if !ok {
+			return nil, nil, fmt.Errorf("settings file %s: unknown setting '%s'", path, key)
+		}
+		var str string
+		if err := json.Unmarshal(rawValue, &str); err == nil {
+			values[s.env] = str
+			continue
+		}
+		if trimmed := bytes.TrimSpace(rawValue); len(trimmed) > 0 && trimmed[0] != '{' && trimmed[0] != '[' && string(trimmed) != "null" {
+			// Numbers and booleans are used as written
+			values[s.env] = string(trimmed)
+			continue
+		}
+		return nil, nil, fmt.Errorf("settings file %s: %s must be a string, number or boolean", path, key)
+	}
+	return values, apps, nil
+}
+
+// printConfig writes the effective value and source of every setting,
+// masking secrets
+func printConfig(w io.Writer, c Config) {
+	for _, s := range settings {
+		value := s.get(c)
+		if s.secret && value != "" {
+			value = "********"
+		}
+		source := c.sources[s.env]
+		if source == "" {
+			source = sourceDefault
+		}
+		fmt.Fprintf(w, "%s=%s (%s)\n", s.env, value, source)
+	}
+	for _, app := range c.Apps {
+		secret := app.SlackSigningSecret
+		if secret != "" {
+			secret = "********"
+		}
+		fmt.Fprintf(w, "APP=%s CONFIG_FILE=%s SLACK_SIGNING_SECRET=%s SLACK_SIGNING_SECRET_FILE=%s OPTIONS_PATH=%s INTERACTIVE_PATH=%s COMMANDS_PATH=%s (%s)\n",
+			app.Name, app.ConfigFile, secret, app.SlackSigningSecretFile, app.OptionsPath, app.InteractivePath, app.CommandsPath, sourceSettings)
+	}
+}
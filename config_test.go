@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeEnv returns a lookupEnv function reading from a map
+func fakeEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+// writeSettingsFile writes a settings file to a temporary directory and returns its path
+func writeSettingsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "settings.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write settings file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	config, err := loadConfig(nil, fakeEnv(map[string]string{"SLACK_SIGNING_SECRET": "secret"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if config.Port != "8080" {
+		t.Errorf("Expected port 8080, got %s", config.Port)
+	}
+	if config.ConfigFile != "catalog.json" {
+		t.Errorf("Expected catalog.json, got %s", config.ConfigFile)
+	}
+	if config.ResponseCacheSize != defaultResponseCacheSize {
+		t.Errorf("Expected cache size %d, got %d", defaultResponseCacheSize, config.ResponseCacheSize)
+	}
+	if config.TLSMinVersion != "1.2" {
+		t.Errorf("Expected TLS 1.2, got %s", config.TLSMinVersion)
+	}
+	if config.SlackSigningSecret != "secret" {
+		t.Errorf("Expected the signing secret from the environment, got %q", config.SlackSigningSecret)
+	}
+}
+
+func TestLoadConfig_Layers(t *testing.T) {
+	settingsFile := writeSettingsFile(t, `{
+		"port": 7000,
+		"config-file": "file.json",
+		"selections-file": "selections.json",
+		"rate-limit-ip-rps": 2.5,
+		"rate-limit-trust-forwarded-for": true
+	}`)
+	env := map[string]string{
+		"SETTINGS_FILE":        settingsFile,
+		"SLACK_SIGNING_SECRET": "secret",
+		"PORT":                 "7001",
+		"CONFIG_FILE":          "env.json",
+		"SELECTIONS_FILE":      "", // empty variables are ignored
+	}
+	args := []string{"--port", "7002", "--rate-limit-team-rps=4"}
+
+	config, err := loadConfig(args, fakeEnv(env))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		got      interface{}
+		expected interface{}
+		source   string
+	}{
+		{name: "PORT", got: config.Port, expected: "7002", source: sourceFlag},
+		{name: "CONFIG_FILE", got: config.ConfigFile, expected: "env.json", source: sourceEnv},
+		{name: "SELECTIONS_FILE", got: config.SelectionsFile, expected: "selections.json", source: sourceSettings},
+		{name: "RATE_LIMIT_IP_RPS", got: config.IPRateLimit, expected: 2.5, source: sourceSettings},
+		{name: "RATE_LIMIT_TRUST_FORWARDED_FOR", got: config.TrustForwardedFor, expected: true, source: sourceSettings},
+		{name: "RATE_LIMIT_TEAM_RPS", got: config.TeamRateLimit, expected: 4.0, source: sourceFlag},
+		{name: "TLS_MIN_VERSION", got: config.TLSMinVersion, expected: "1.2", source: sourceDefault},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, tt.got)
+			}
+			if got := config.sources[tt.name]; got != tt.source {
+				t.Errorf("Expected source %s, got %s", tt.source, got)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_SettingsFlagOverridesEnv(t *testing.T) {
+	fromEnv := writeSettingsFile(t, `{"port": "7000"}`)
+	fromFlag := writeSettingsFile(t, `{"port": "7001"}`)
+	env := map[string]string{"SETTINGS_FILE": fromEnv, "SLACK_APP_TOKEN": "xapp-token"}
+
+	config, err := loadConfig([]string{"--settings", fromFlag}, fakeEnv(env))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Port != "7001" {
+		t.Errorf("Expected the port from the --settings file, got %s", config.Port)
+	}
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	secret := map[string]string{"SLACK_SIGNING_SECRET": "secret"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		settings string
+		errText  string
+	}{
+		{name: "missing secret", env: map[string]string{}, errText: "SLACK_SIGNING_SECRET or SLACK_APP_TOKEN is required"},
+		{name: "invalid env value", env: map[string]string{"SLACK_SIGNING_SECRET": "s", "RESPONSE_CACHE_SIZE": "-1"}, errText: "RESPONSE_CACHE_SIZE: must be a non-negative integer"},
+		{name: "invalid flag value", args: []string{"--rate-limit-ip-rps", "fast"}, env: secret, errText: "rate-limit-ip-rps: must be a non-negative number"},
+		{name: "invalid bool flag", args: []string{"--rate-limit-trust-forwarded-for=maybe"}, env: secret, errText: "must be true or false"},
+		{name: "unknown flag", args: []string{"--colour", "blue"}, env: secret, errText: "flag provided but not defined"},
+		{name: "signing secret flag", args: []string{"--slack-signing-secret=secret"}, errText: "flag provided but not defined"},
+		{name: "app token flag", args: []string{"--slack-app-token", "xapp-token"}, errText: "flag provided but not defined"},
+		{name: "unknown setting", settings: `{"colour": "blue"}`, env: secret, errText: "unknown setting 'colour'"},
+		{name: "invalid settings value", settings: `{"port": [8080]}`, env: secret, errText: "port must be a string, number or boolean"},
+		{name: "invalid settings JSON", settings: `{`, env: secret, errText: "parsing settings file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.settings != "" {
+				args = append(args, "--settings", writeSettingsFile(t, tt.settings))
+			}
+			_, err := loadConfig(args, fakeEnv(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected an error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+}
+
+func TestPrintConfig_MasksSecrets(t *testing.T) {
+	env := map[string]string{"SLACK_SIGNING_SECRET": "super-secret", "PORT": "9000"}
+	config, err := loadConfig([]string{"--print-config"}, fakeEnv(env))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !config.PrintConfig {
+		t.Error("Expected PrintConfig to be set")
+	}
+
+	var out bytes.Buffer
+	printConfig(&out, config)
+	printed := out.String()
+
+	if strings.Contains(printed, "super-secret") {
+		t.Errorf("Expected the signing secret to be masked, got:\n%s", printed)
+	}
+	for _, line := range []string{
+		"SLACK_SIGNING_SECRET=******** (environment)\n",
+		"SLACK_APP_TOKEN= (default)\n",
+		"PORT=9000 (environment)\n",
+		"CONFIG_FILE=catalog.json (default)\n",
+	} {
+		if !strings.Contains(printed, line) {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, printed)
+		}
+	}
+}
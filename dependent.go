@@ -0,0 +1,46 @@
+package main
+
+// indexFor returns the index of the options an entry offers for a request.
+// Entries with DependsOn choose their options by the current value of that
+// input in the request's view state, normalized like the OptionsByValue keys,
+// falling back to Options when the input has no value or no options are
+// listed for it.
+func (e *CatalogEntry) indexFor(req SlackRequest) *optionIndex {
+	if e.DependsOn == "" {
+		return e.index
+	}
+
+	value, ok := stateValue(req, e.DependsOn)
+	if !ok {
+		return e.index
+	}
+	if ix, found := e.indexByValue[dependentKey(value, e.foldDependsOnCase)]; found {
+		return ix
+	}
+	return e.index
+}
+
+// stateValue returns the current value of the input with the given action_id
+// in the view state of a request. For select menus this is the selected
+// option's value; for multi-selects, the first selected value.
+func stateValue(req SlackRequest, actionID string) (string, bool) {
+	if req.View == nil {
+		return "", false
+	}
+
+	for _, block := range req.View.State.Values {
+		state, ok := block[actionID]
+		if !ok {
+			continue
+		}
+		switch {
+		case state.SelectedOption != nil:
+			return state.SelectedOption.Value, true
+		case len(state.SelectedOptions) > 0:
+			return state.SelectedOptions[0].Value, true
+		case state.Value != "":
+			return state.Value, true
+		}
+	}
+	return "", false
+}
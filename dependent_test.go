@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+)
+
+// viewWithSelection builds a view whose state has a single selected option
+func viewWithSelection(blockID, actionID, value string) *SlackView {
+	return &SlackView{
+		ID: "V1",
+		State: SlackViewState{Values: map[string]map[string]SlackStateValue{
+			blockID: {actionID: {Type: "external_select", SelectedOption: &SlackSelectedOption{Value: value}}},
+		}},
+	}
+}
+
+func TestStateValue(t *testing.T) {
+	view := &SlackView{State: SlackViewState{Values: map[string]map[string]SlackStateValue{
+		"repo_block":  {"repo": {Type: "external_select", SelectedOption: &SlackSelectedOption{Value: "InnerGate"}}},
+		"teams_block": {"teams": {Type: "multi_static_select", SelectedOptions: []SlackSelectedOption{{Value: "a"}, {Value: "b"}}}},
+		"title_block": {"title": {Type: "plain_text_input", Value: "Hello"}},
+		"empty_block": {"empty": {Type: "external_select"}},
+	}}}
+	req := SlackRequest{View: view}
+
+	tests := []struct {
+		actionID string
+		value    string
+		ok       bool
+	}{
+		{actionID: "repo", value: "InnerGate", ok: true},
+		{actionID: "teams", value: "a", ok: true},
+		{actionID: "title", value: "Hello", ok: true},
+		{actionID: "empty", value: "", ok: false},
+		{actionID: "missing", value: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.actionID, func(t *testing.T) {
+			value, ok := stateValue(req, tt.actionID)
+			if value != tt.value || ok != tt.ok {
+				t.Errorf("stateValue(%q) = (%q, %v), want (%q, %v)", tt.actionID, value, ok, tt.value, tt.ok)
+			}
+		})
+	}
+
+	if _, ok := stateValue(SlackRequest{}, "repo"); ok {
+		t.Error("Expected no value without a view")
+	}
+}
+
+func TestHandleRequest_DependentOptionsOfFoldedInput(t *testing.T) {
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID:      "repo",
+			FoldValueCase: true,
+			Options:       []Option{{Text: "InnerGate", Value: "InnerGate"}},
+		},
+		{
+			ActionID:       "environment",
+			DependsOn:      "repo",
+			Options:        []Option{{Text: "Production", Value: "prod"}},
+			OptionsByValue: map[string][]Option{"InnerGate": {{Text: "Staging", Value: "staging"}}},
+		},
+	})
+
+	// The repo input sends its lowercased value, which still selects the
+	// options listed under the key as written
+	for _, value := range []string{"innergate", "InnerGate"} {
+		response := decodeTestResponse(t, sendTestRequest(t, "test-secret", SlackRequest{
+			Type:     "block_suggestion",
+			ActionID: "environment",
+			View:     viewWithSelection("repo_block", "repo", value),
+		}))
+		if len(response.Options) != 1 || response.Options[0].Value != "staging" {
+			t.Errorf("%s: expected [staging], got %+v", value, response.Options)
+		}
+	}
+}
+
+func TestHandleRequest_DependentOptions(t *testing.T) {
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID:  "environment",
+			DependsOn: "repo",
+			Options:   []Option{{Text: "Production", Value: "prod"}},
+			OptionsByValue: map[string][]Option{
+				"InnerGate": {{Text: "Staging", Value: "staging"}, {Text: "Production", Value: "prod"}},
+				"Poppit":    {{Text: "Sandbox", Value: "sandbox"}},
+			},
+		},
+	})
+	secret := "test-secret"
+
+	tests := []struct {
+		name     string
+		view     *SlackView
+		query    string
+		expected []string
+	}{
+		{name: "no view uses defaults", view: nil, expected: []string{"prod"}},
+		{name: "first repo", view: viewWithSelection("repo_block", "repo", "InnerGate"), expected: []string{"staging", "prod"}},
+		{name: "second repo", view: viewWithSelection("repo_block", "repo", "Poppit"), expected: []string{"sandbox"}},
+		{name: "unknown repo uses defaults", view: viewWithSelection("repo_block", "repo", "Other"), expected: []string{"prod"}},
+		{name: "query filters dependent options", view: viewWithSelection("repo_block", "repo", "InnerGate"), query: "stag", expected: []string{"staging"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := decodeTestResponse(t, sendTestRequest(t, secret, SlackRequest{
+				Type:     "block_suggestion",
+				ActionID: "environment",
+				Value:    tt.query,
+				View:     tt.view,
+			}))
+
+			if len(response.Options) != len(tt.expected) {
+				t.Fatalf("Expected %d options, got %d", len(tt.expected), len(response.Options))
+			}
+			for i, value := range tt.expected {
+				if response.Options[i].Value != value {
+					t.Errorf("Expected option %d to be '%s', got '%s'", i, value, response.Options[i].Value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// searchKeySeparator separates the fields of an option's search key so that a
+// search term never matches across two fields
+const searchKeySeparator = "\x00"
+
+// foldSearchText folds s for accent- and case-insensitive comparison: it is
+// decomposed, stripped of combining marks (diacritics), and Unicode case
+// folded, so that "Café" and "CAFE" both become "cafe" and "Straße" becomes
+// "strasse".
+func foldSearchText(s string) string {
+	if isASCII(s) {
+		return strings.ToLower(s)
+	}
+
+	// Transformers are stateful, so a new chain is needed for every call
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), cases.Fold(), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		return strings.ToLower(s)
+	}
+	return folded
+}
+
+// isASCII reports whether s contains only ASCII characters, which need no
+// decomposition or special case folding
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// buildSearchKey folds and joins every searchable field of an option
+func buildSearchKey(opt Option) string {
+	fields := make([]string, 0, 3+len(opt.Aliases)+len(opt.Keywords))
+	fields = append(fields, opt.Text, opt.Value, opt.Description)
+	fields = append(fields, opt.Aliases...)
+	fields = append(fields, opt.Keywords...)
+	return foldSearchText(strings.Join(fields, searchKeySeparator))
+}
+
+// buildTagKeys folds the tags of an option
+func buildTagKeys(opt Option) []string {
+	keys := make([]string, len(opt.Tags))
+	for i, tag := range opt.Tags {
+		keys[i] = foldSearchText(tag)
+	}
+	return keys
+}
+
+// prepareSearchKeys precomputes the folded search key and tags of an option
+// so they are not recomputed on every request
+func prepareSearchKeys(opt *Option) {
+	opt.searchKey = buildSearchKey(*opt)
+	opt.tagKeys = buildTagKeys(*opt)
+	opt.searchKeysReady = true
+}
+
+// searchKeys returns the folded search key and tags of an option, computing
+// them if the option was not prepared by setCatalog
+func (o Option) searchKeys() (string, []string) {
+	if o.searchKeysReady {
+		return o.searchKey, o.tagKeys
+	}
+	return buildSearchKey(o), buildTagKeys(o)
+}
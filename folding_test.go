@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFoldSearchText(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "Café", expected: "cafe"},
+		{input: "Cafe\u0301", expected: "cafe"},
+		{input: "CRÈME BRÛLÉE", expected: "creme brulee"},
+		{input: "Straße", expected: "strasse"},
+		{input: "ΣΊΣΥΦΟΣ", expected: "σισυφοσ"},
+		{input: "plain", expected: "plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := foldSearchText(tt.input); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLookupOptions_AccentAndCaseInsensitive(t *testing.T) {
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID: "places",
+			Options: []Option{
+				{Text: "Café Nero", Value: "cafe-nero"},
+				{Text: "Hauptstraße", Value: "hauptstrasse", Aliases: []string{"Main Street"}},
+				{Text: "Zürich", Value: "zrh", Tags: []string{"Région:Europe"}},
+			},
+		},
+	})
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "cafe", expected: []string{"cafe-nero"}},
+		{query: "CAFÉ", expected: []string{"cafe-nero"}},
+		{query: "strasse", expected: []string{"hauptstrasse"}},
+		{query: "STRAẞE", expected: []string{"hauptstrasse"}},
+		{query: "zurich", expected: []string{"zrh"}},
+		{query: "region:europe", expected: []string{"zrh"}},
+		{query: "main street", expected: []string{"hauptstrasse"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := optionValues(currentCatalog().lookupOptions(context.Background(), SlackRequest{ActionID: "places", Value: tt.query}))
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"iter"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// trigramLength is the length in bytes of the substrings indexed by an optionIndex
+const trigramLength = 3
+
+// catalogVersions numbers each catalog as it is loaded
+var catalogVersions atomic.Uint64
+
+// catalogStore holds the catalog served by a Slack app. A reload builds a new
+// catalogIndex and swaps it in whole, so a request never sees a partially
+// loaded catalog.
+type catalogStore struct {
+	active atomic.Pointer[catalogIndex]
+}
+
+// defaultCatalog holds the catalog of the app configured by the top-level
+// settings, which is also served over Socket Mode
+var defaultCatalog = &catalogStore{}
+
+// catalogIndex is a loaded catalog together with the lookup structures built from it
+type catalogIndex struct {
+	version  uint64 // increases with every load, so results can be cached per version
+	entries  []CatalogEntry
+	byAction map[string][]*CatalogEntry // entries sharing each action ID, in file order
+}
+
+// current returns the active catalog, or an empty one if none is loaded
+func (s *catalogStore) current() *catalogIndex {
+	if c := s.active.Load(); c != nil {
+		return c
+	}
+	return &catalogIndex{}
+}
+
+// currentCatalog returns the active catalog of the default app
+func currentCatalog() *catalogIndex {
+	return defaultCatalog.current()
+}
+
+// newCatalogIndex indexes prepared catalog entries by action ID and builds
+// the option index of every entry
+func newCatalogIndex(entries []CatalogEntry) *catalogIndex {
+	c := &catalogIndex{
+		version:  catalogVersions.Add(1),
+		entries:  entries,
+		byAction: make(map[string][]*CatalogEntry, len(entries)),
+	}
+	for i := range entries {
+		entry := &entries[i]
+		entry.index = newOptionIndex(entry.Options)
+		if entry.OptionsByValue != nil {
+			entry.indexByValue = make(map[string]*optionIndex, len(entry.OptionsByValue))
+			for key, options := range entry.OptionsByValue {
+				entry.indexByValue[key] = newOptionIndex(options)
+			}
+		}
+		c.byAction[entry.ActionID] = append(c.byAction[entry.ActionID], entry)
+	}
+	return c
+}
+
+// optionIndex is a trigram index over the search keys of a list of options.
+// It narrows a query down to the options that can possibly match it, which
+// the query then checks one by one.
+type optionIndex struct {
+	options    []Option
+	pinned     []int32            // positions of the pinned options, which match any query
+	trigrams   map[string][]int32 // ascending positions of the options containing each trigram
+	boundaries []time.Time        // times at which an option's validity window opens or closes
+	scope      scopeMask          // parts of the request scope the options' visibility rules look at
+}
+
+// newOptionIndex builds the trigram index of a list of options
+func newOptionIndex(options []Option) *optionIndex {
+	ix := &optionIndex{
+		options:    options,
+		trigrams:   make(map[string][]int32),
+		boundaries: windowBoundaries(options),
+	}
+	for i, opt := range options {
+		pos := int32(i)
+		if opt.Pinned {
+			ix.pinned = append(ix.pinned, pos)
+		}
+		ix.scope.add(opt.Visibility)
+
+		key, _ := opt.searchKeys()
+		for j := 0; j+trigramLength <= len(key); j++ {
+			gram := key[j : j+trigramLength]
+			if strings.Contains(gram, searchKeySeparator) {
+				continue
+			}
+			postings := ix.trigrams[gram]
+			if n := len(postings); n > 0 && postings[n-1] == pos {
+				continue
+			}
+			ix.trigrams[gram] = append(postings, pos)
+		}
+	}
+	return ix
+}
+
+// candidates yields, in their original order, the pinned options and the
+// options containing every trigram of the query's free-text terms. Terms
+// shorter than a trigram do not narrow the result, and tag filters are left
+// to the caller.
+func (ix *optionIndex) candidates(q Query) iter.Seq[Option] {
+	var postings [][]int32
+	for _, term := range q.Terms {
+		for j := 0; j+trigramLength <= len(term); j++ {
+			postings = append(postings, ix.trigrams[term[j:j+trigramLength]])
+		}
+	}
+	if len(postings) == 0 {
+		return slices.Values(ix.options)
+	}
+
+	// Intersecting the shortest lists first keeps the intermediate results small
+	slices.SortFunc(postings, func(a, b []int32) int { return len(a) - len(b) })
+	positions := postings[0]
+	for _, p := range postings[1:] {
+		if len(positions) == 0 {
+			break
+		}
+		positions = intersectPositions(positions, p)
+	}
+
+	positions = unionPositions(positions, ix.pinned)
+	return func(yield func(Option) bool) {
+		for _, pos := range positions {
+			if !yield(ix.options[pos]) {
+				return
+			}
+		}
+	}
+}
+
+// intersectPositions returns the positions present in both ascending lists
+func intersectPositions(a, b []int32) []int32 {
+	result := make([]int32, 0, min(len(a), len(b)))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// unionPositions merges two ascending lists of positions without duplicates
+func unionPositions(a, b []int32) []int32 {
+	if len(b) == 0 {
+		return a
+	}
+	result := make([]int32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i] < b[j]):
+			result = append(result, a[i])
+			i++
+		case i == len(a) || b[j] < a[i]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
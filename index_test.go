@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOptionIndex_Candidates(t *testing.T) {
+	options := []Option{
+		{Text: "Deploy Bot", Value: "deploy-bot"},
+		{Text: "Docs", Value: "docs", Pinned: true},
+		{Text: "Deployment Dashboard", Value: "dash"},
+		{Text: "Release Notes", Value: "notes", Aliases: []string{"changelog"}},
+	}
+	for i := range options {
+		prepareSearchKeys(&options[i])
+	}
+	ix := newOptionIndex(options)
+
+	tests := []struct {
+		raw      string
+		expected []string
+	}{
+		{raw: "", expected: []string{"deploy-bot", "docs", "dash", "notes"}},
+		{raw: "de", expected: []string{"deploy-bot", "docs", "dash", "notes"}},
+		{raw: "deploy", expected: []string{"deploy-bot", "docs", "dash"}},
+		{raw: "deploy dash", expected: []string{"docs", "dash"}},
+		{raw: "changelog", expected: []string{"docs", "notes"}},
+		{raw: "missing", expected: []string{"docs"}},
+		{raw: "#backend", expected: []string{"deploy-bot", "docs", "dash", "notes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got := optionValues(slices.Collect(ix.candidates(parseQuery(tt.raw))))
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOptionIndex_MatchesLinearScan(t *testing.T) {
+	options := benchmarkOptions(2000)
+	for i := range options {
+		prepareSearchKeys(&options[i])
+	}
+	ix := newOptionIndex(options)
+
+	for _, raw := range []string{"", "1", "service", "service-12", "team 7", "#tier:1 api", "-#tier:2 worker 3", "nothing"} {
+		t.Run(raw, func(t *testing.T) {
+			q := parseQuery(raw)
+			var indexed, linear []string
+			for opt := range ix.candidates(q) {
+				if q.matches(opt) {
+					indexed = append(indexed, opt.Value)
+				}
+			}
+			for _, opt := range options {
+				if q.matches(opt) {
+					linear = append(linear, opt.Value)
+				}
+			}
+			if !reflect.DeepEqual(indexed, linear) {
+				t.Errorf("Expected %d option(s) from the linear scan, got %d from the index", len(linear), len(indexed))
+			}
+		})
+	}
+}
+
+func TestSetCatalog_InvalidCatalogKeepsActive(t *testing.T) {
+	setupTestCatalog()
+	active := currentCatalog()
+
+	err := setCatalog([]CatalogEntry{{ActionID: "broken", Sort: "sideways"}})
+	if err == nil {
+		t.Fatal("Expected an error for an invalid catalog")
+	}
+	if currentCatalog() != active {
+		t.Error("Expected the active catalog to be kept after a failed load")
+	}
+	if currentCatalog().findEntry(SlackRequest{ActionID: "test_action"}) == nil {
+		t.Error("Expected the previous catalog to keep serving requests")
+	}
+}
+
+// benchmarkOptions generates n options with varied text, descriptions and tags
+func benchmarkOptions(n int) []Option {
+	kinds := []string{"api", "worker", "frontend", "database", "cache"}
+	options := make([]Option, n)
+	for i := range options {
+		kind := kinds[i%len(kinds)]
+		options[i] = Option{
+			Text:        fmt.Sprintf("Service %d (%s)", i, kind),
+			Value:       fmt.Sprintf("service-%d", i),
+			Description: fmt.Sprintf("Owned by team %d", i%50),
+			Aliases:     []string{fmt.Sprintf("svc%d", i)},
+			Tags:        []string{fmt.Sprintf("tier:%d", i%3)},
+		}
+	}
+	return options
+}
+
+// benchmarkCatalog generates a catalog of entries action IDs, each with
+// optionsPerEntry options
+func benchmarkCatalog(entries, optionsPerEntry int) []CatalogEntry {
+	catalog := make([]CatalogEntry, entries)
+	for i := range catalog {
+		catalog[i] = CatalogEntry{
+			ActionID: fmt.Sprintf("action_%d", i),
+			Options:  benchmarkOptions(optionsPerEntry),
+		}
+	}
+	return catalog
+}
+
+// indexedLookup finds the options matching a request through the catalog index
+func indexedLookup(req SlackRequest) []Option {
+	matched := currentCatalog().findEntry(req)
+	if matched == nil {
+		return nil
+	}
+
+	query := parseQuery(req.Value)
+	var options []Option
+	for opt := range matched.indexFor(req).candidates(query) {
+		if query.matches(opt) {
+			options = append(options, opt)
+		}
+	}
+	return options
+}
+
+// linearLookup finds the options matching a request the way they were found
+// before the index: it scans every entry for the action ID and then folds
+// and checks every option against the query, on every request
+func linearLookup(entries []CatalogEntry, req SlackRequest) []Option {
+	var matched *CatalogEntry
+	for i := range entries {
+		if entries[i].ActionID == req.ActionID && entries[i].route.matches(req) {
+			matched = &entries[i]
+		}
+	}
+	if matched == nil {
+		return nil
+	}
+
+	query := parseQuery(req.Value)
+	var options []Option
+	for _, opt := range matched.Options {
+		// Drop the search keys precomputed at load time so they are folded
+		// again, as the options were before they had any
+		opt.searchKeysReady = false
+		if query.matches(opt) {
+			options = append(options, opt)
+		}
+	}
+	return options
+}
+
+var benchmarkQueries = []string{"service-4999", "team 42", "database", "svc123", "#tier:1 cache"}
+
+// setBenchmarkCatalog installs a catalog of 100 small entries followed by one
+// with 50,000 options, the worst case for a scan
+func setBenchmarkCatalog() []CatalogEntry {
+	entries := benchmarkCatalog(100, 500)
+	entries = append(entries, CatalogEntry{ActionID: "large", Options: benchmarkOptions(50000)})
+	setTestCatalog(entries)
+	return entries
+}
+
+func BenchmarkLookup_Indexed(b *testing.B) {
+	setBenchmarkCatalog()
+	defer setupTestCatalog()
+
+	for _, raw := range benchmarkQueries {
+		req := SlackRequest{ActionID: "large", Value: raw}
+		b.Run(strings.ReplaceAll(raw, " ", "_"), func(b *testing.B) {
+			for b.Loop() {
+				indexedLookup(req)
+			}
+		})
+	}
+}
+
+func BenchmarkLookup_Linear(b *testing.B) {
+	entries := setBenchmarkCatalog()
+	defer setupTestCatalog()
+
+	for _, raw := range benchmarkQueries {
+		req := SlackRequest{ActionID: "large", Value: raw}
+		b.Run(strings.ReplaceAll(raw, " ", "_"), func(b *testing.B) {
+			for b.Loop() {
+				linearLookup(entries, req)
+			}
+		})
+	}
+}
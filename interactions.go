@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log"
+)
+
+// interactionHandler handles a single type of Slack interaction payload using
+// the catalog of the app that received it. The returned value is encoded as
+// the JSON response body; a nil value is acknowledged with an empty 200 response.
+type interactionHandler func(ctx context.Context, c *catalogIndex, req SlackRequest) (interface{}, error)
+
+// interactionHandlers maps Slack payload types to their handlers
+var interactionHandlers = map[string]interactionHandler{
+	"block_suggestion":    handleBlockSuggestion,
+	"dialog_suggestion":   handleDialogSuggestion,
+	"interactive_message": handleInteractiveMessage,
+	"block_actions":       recordSelections,
+	"view_submission":     recordSelections,
+	"view_closed":         acknowledgeInteraction,
+	"shortcut":            acknowledgeInteraction,
+}
+
+// registerInteractionHandler installs the handler for a payload type, replacing any existing one
+func registerInteractionHandler(payloadType string, handler interactionHandler) {
+	interactionHandlers[payloadType] = handler
+}
+
+// dispatchInteraction routes a request to the handler registered for its type.
+// Requests without a type are treated as block_suggestion for backward
+// compatibility, and unsupported types are acknowledged without a body.
+func dispatchInteraction(ctx context.Context, c *catalogIndex, req SlackRequest) (interface{}, error) {
+	payloadType := req.Type
+	if payloadType == "" {
+		payloadType = "block_suggestion"
+	}
+
+	handler, ok := interactionHandlers[payloadType]
+	if !ok {
+		log.Printf("Acknowledging unsupported interaction type: %s", payloadType)
+		return nil, nil
+	}
+	return handler(ctx, c, req)
+}
+
+// acknowledgeInteraction acknowledges an interaction without doing anything
+func acknowledgeInteraction(_ context.Context, _ *catalogIndex, req SlackRequest) (interface{}, error) {
+	log.Printf("Acknowledging %s interaction", req.Type)
+	return nil, nil
+}
+
+// legacyActionID returns the catalog action ID of a legacy dialog or message
+// menu, which identifies itself with name rather than action_id
+func legacyActionID(req SlackRequest) string {
+	if req.Name != "" {
+		return req.Name
+	}
+	return req.ActionID
+}
+
+// handleDialogSuggestion returns the catalog options matching a legacy dialog_suggestion request
+func handleDialogSuggestion(ctx context.Context, c *catalogIndex, req SlackRequest) (interface{}, error) {
+	req.ActionID = legacyActionID(req)
+	log.Printf("Received dialog request for name: %s", req.ActionID)
+
+	options := truncateOptions(c.lookupOptions(ctx, req))
+	dialogOptions := make([]SlackDialogOption, len(options))
+	for i, opt := range options {
+		dialogOptions[i] = SlackDialogOption{Label: opt.Text, Value: opt.Value}
+	}
+	return SlackDialogResponse{Options: dialogOptions}, nil
+}
+
+// handleInteractiveMessage returns the catalog options matching a legacy
+// message menu options load. Interactive message payloads that carry actions
+// are button or menu selections rather than options loads, so they are only
+// acknowledged.
+func handleInteractiveMessage(ctx context.Context, c *catalogIndex, req SlackRequest) (interface{}, error) {
+	if len(req.Actions) > 0 {
+		return acknowledgeInteraction(ctx, c, req)
+	}
+
+	req.ActionID = legacyActionID(req)
+	log.Printf("Received message menu request for name: %s", req.ActionID)
+
+	options := truncateOptions(c.lookupOptions(ctx, req))
+	messageOptions := make([]SlackMessageOption, len(options))
+	for i, opt := range options {
+		messageOptions[i] = SlackMessageOption{Text: opt.Text, Value: opt.Value, Description: opt.Description}
+	}
+	return SlackMessageResponse{Options: messageOptions}, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestHandleRequest_AcknowledgesInteractionTypes(t *testing.T) {
+	setupTestCatalog()
+	secret := "test-secret"
+
+	for _, payloadType := range []string{"block_actions", "view_submission", "view_closed", "shortcut", "message_action"} {
+		t.Run(payloadType, func(t *testing.T) {
+			rr := sendTestRequest(t, secret, SlackRequest{
+				Type:     payloadType,
+				ActionID: "test_action",
+			})
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+			}
+			if rr.Body.Len() != 0 {
+				t.Errorf("Expected empty body, got %q", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleRequest_EmptyTypeIsBlockSuggestion(t *testing.T) {
+	setupTestCatalog()
+	secret := "test-secret"
+
+	rr := sendTestRequest(t, secret, SlackRequest{ActionID: "test_action"})
+	response := decodeTestResponse(t, rr)
+
+	if len(response.Options) != 2 {
+		t.Errorf("Expected 2 options, got %d", len(response.Options))
+	}
+}
+
+func TestRegisterInteractionHandler(t *testing.T) {
+	secret := "test-secret"
+	original, hadOriginal := interactionHandlers["shortcut"]
+	defer func() {
+		if hadOriginal {
+			interactionHandlers["shortcut"] = original
+		} else {
+			delete(interactionHandlers, "shortcut")
+		}
+	}()
+
+	// A custom handler's response is encoded as the response body
+	registerInteractionHandler("shortcut", func(ctx context.Context, c *catalogIndex, req SlackRequest) (interface{}, error) {
+		return map[string]string{"handled": req.Type}, nil
+	})
+	rr := sendTestRequest(t, secret, SlackRequest{Type: "shortcut"})
+	if rr.Code != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if got := rr.Body.String(); got != "{\"handled\":\"shortcut\"}\n" {
+		t.Errorf("Unexpected body: %q", got)
+	}
+
+	// A handler error results in a 500
+	registerInteractionHandler("shortcut", func(ctx context.Context, c *catalogIndex, req SlackRequest) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	rr = sendTestRequest(t, secret, SlackRequest{Type: "shortcut"})
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestHandleRequest_DialogSuggestion(t *testing.T) {
+	setupTestCatalogWithMetadata()
+	secret := "test-secret"
+
+	rr := sendTestRequest(t, secret, map[string]interface{}{
+		"type":        "dialog_suggestion",
+		"callback_id": "legacy_dialog",
+		"name":        "test_action",
+		"value":       "gateway",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var response map[string][]map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	options := response["options"]
+	if len(options) != 1 {
+		t.Fatalf("Expected 1 option, got %d", len(options))
+	}
+	expected := map[string]interface{}{"label": "InnerGate", "value": "InnerGate"}
+	if !reflect.DeepEqual(options[0], expected) {
+		t.Errorf("Expected %v, got %v", expected, options[0])
+	}
+}
+
+func TestHandleRequest_InteractiveMessageOptionsLoad(t *testing.T) {
+	setupTestCatalogWithMetadata()
+	secret := "test-secret"
+
+	rr := sendTestRequest(t, secret, map[string]interface{}{
+		"type":        "interactive_message",
+		"callback_id": "legacy_menu",
+		"name":        "test_action",
+		"value":       "gateway",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var response map[string][]map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	options := response["options"]
+	if len(options) != 1 {
+		t.Fatalf("Expected 1 option, got %d", len(options))
+	}
+	expected := map[string]interface{}{"text": "InnerGate", "value": "InnerGate", "description": "Internal API gateway"}
+	if !reflect.DeepEqual(options[0], expected) {
+		t.Errorf("Expected %v, got %v", expected, options[0])
+	}
+}
+
+func TestHandleRequest_InteractiveMessageActionIsAcknowledged(t *testing.T) {
+	setupTestCatalog()
+	secret := "test-secret"
+
+	rr := sendTestRequest(t, secret, map[string]interface{}{
+		"type":        "interactive_message",
+		"callback_id": "legacy_menu",
+		"actions": []map[string]interface{}{
+			{"name": "test_action", "type": "select", "selected_options": []map[string]string{{"value": "opt1"}}},
+		},
+	})
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got %q", rr.Body.String())
+	}
+}
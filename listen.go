@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// unixAddressPrefix marks a listen address as a Unix domain socket path
+	unixAddressPrefix = "unix:"
+	// systemdFirstFD is the first file descriptor passed by systemd socket activation
+	systemdFirstFD = 3
+)
+
+// listenAddress returns the address to listen on: LISTEN_ADDRESS if set,
+// otherwise PORT, which may also be a "unix:" path
+func listenAddress(config Config) string {
+	if config.ListenAddress != "" {
+		return config.ListenAddress
+	}
+	if strings.HasPrefix(config.Port, unixAddressPrefix) || strings.Contains(config.Port, ":") {
+		return config.Port
+	}
+	return ":" + config.Port
+}
+
+// listen opens the server's listener. A listener passed by systemd socket
+// activation takes precedence; otherwise the address is either a TCP
+// address such as ":8080" or a Unix domain socket such as "unix:/run/octocatalog.sock".
+func listen(address string, lookupEnv func(string) (string, bool)) (net.Listener, error) {
+	n, err := systemdListenFDs(lookupEnv, os.Getpid())
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		if n > 1 {
+			log.Printf("Warning: systemd passed %d sockets, only the first is used", n)
+		}
+		listener, err := listenerFromFD(systemdFirstFD)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using the socket passed by systemd (%s)", listener.Addr())
+		return listener, nil
+	}
+
+	return listenOn(address)
+}
+
+// listenOn listens on a TCP address or, with the unix: prefix, a Unix domain socket
+func listenOn(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, unixAddressPrefix); ok {
+		return listenUnix(path)
+	}
+	return net.Listen("tcp", address)
+}
+
+// systemdListenFDs returns how many sockets systemd passed to this process,
+// following the sd_listen_fds protocol: LISTEN_FDS only applies if
+// LISTEN_PID names the current process.
+func systemdListenFDs(lookupEnv func(string) (string, bool), pid int) (int, error) {
+	pidValue, ok := lookupEnv("LISTEN_PID")
+	if !ok || pidValue != strconv.Itoa(pid) {
+		return 0, nil
+	}
+
+	fdsValue, _ := lookupEnv("LISTEN_FDS")
+	n, err := strconv.Atoi(fdsValue)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid LISTEN_FDS %q", fdsValue)
+	}
+	return n, nil
+}
+
+// listenerFromFD wraps an inherited socket file descriptor in a listener.
+// The listener holds a duplicate of the descriptor, so the original is closed.
+func listenerFromFD(fd uintptr) (net.Listener, error) {
+	file := os.NewFile(fd, "systemd-socket")
+	defer file.Close()
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("using systemd socket: %w", err)
+	}
+	return listener, nil
+}
+
+// listenUnix listens on a Unix domain socket, first removing a stale socket
+// file left behind by a previous process that did not shut down cleanly
+func listenUnix(path string) (net.Listener, error) {
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("checking socket path: %w", err)
+	case info.Mode()&fs.ModeSocket == 0:
+		return nil, fmt.Errorf("%s exists and is not a socket", path)
+	default:
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", path, err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// shortTempDir creates a temporary directory with a path short enough for a Unix socket
+func shortTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "oc")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestListenAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected string
+	}{
+		{name: "port", config: Config{Port: "8080"}, expected: ":8080"},
+		{name: "host and port", config: Config{Port: "127.0.0.1:8080"}, expected: "127.0.0.1:8080"},
+		{name: "unix socket in PORT", config: Config{Port: "unix:/run/oc.sock"}, expected: "unix:/run/oc.sock"},
+		{name: "listen address wins", config: Config{Port: "8080", ListenAddress: "unix:/run/oc.sock"}, expected: "unix:/run/oc.sock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listenAddress(tt.config); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSystemdListenFDs(t *testing.T) {
+	pid := os.Getpid()
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected int
+		wantErr  bool
+	}{
+		{name: "not activated", env: map[string]string{}, expected: 0},
+		{name: "activated", env: map[string]string{"LISTEN_PID": strconv.Itoa(pid), "LISTEN_FDS": "1"}, expected: 1},
+		{name: "other process", env: map[string]string{"LISTEN_PID": strconv.Itoa(pid + 1), "LISTEN_FDS": "1"}, expected: 0},
+		{name: "invalid count", env: map[string]string{"LISTEN_PID": strconv.Itoa(pid), "LISTEN_FDS": "many"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := systemdListenFDs(fakeEnv(tt.env), pid)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestListen_UnixSocket(t *testing.T) {
+	path := filepath.Join(shortTempDir(t), "oc.sock")
+	listener, err := listen(unixAddressPrefix+path, fakeEnv(nil))
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://octocatalog/")
+	if err != nil {
+		t.Fatalf("Request over the socket failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "ok" {
+		t.Errorf("Expected 'ok', got %q", body)
+	}
+}
+
+func TestListenUnix_ExistingPath(t *testing.T) {
+	dir := shortTempDir(t)
+
+	// A socket left behind by a process that did not shut down cleanly is replaced
+	stalePath := filepath.Join(dir, "stale.sock")
+	stale, err := net.Listen("unix", stalePath)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	listener, err := listenUnix(stalePath)
+	if err != nil {
+		t.Errorf("Expected the stale socket to be replaced, got %v", err)
+	} else {
+		defer listener.Close()
+	}
+
+	// A socket another process is serving is left alone
+	if _, err := listenUnix(stalePath); err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("Expected an error for a socket in use, got %v", err)
+	}
+
+	// So is anything that is not a socket
+	filePath := filepath.Join(dir, "file")
+	if err := os.WriteFile(filePath, nil, 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := listenUnix(filePath); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("Expected an error for a regular file, got %v", err)
+	}
+}
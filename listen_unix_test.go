@@ -0,0 +1,37 @@
+//go:build unix
+
+package main
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestListenerFromFD(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer tcp.Close()
+	file, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("Failed to get the listener's file: %v", err)
+	}
+	defer file.Close()
+
+	// Pass a descriptor of its own, as systemd would, since listenerFromFD closes it
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatalf("Failed to duplicate the descriptor: %v", err)
+	}
+
+	listener, err := listenerFromFD(uintptr(fd))
+	if err != nil {
+		t.Fatalf("Failed to use the inherited descriptor: %v", err)
+	}
+	defer listener.Close()
+	if listener.Addr().String() != tcp.Addr().String() {
+		t.Errorf("Expected address %s, got %s", tcp.Addr(), listener.Addr())
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -12,36 +15,167 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Port               string
-	SlackSigningSecret string
-	ConfigFile         string
+	Port                   string
+	ListenAddress          string
+	SlackSigningSecret     string
+	SlackSigningSecretFile string
+	ConfigFile             string
+	SelectionsFile         string
+	SlackAppToken          string
+	SlackAppTokenFile      string
+	SocketModeURL          string
+	ResponseCacheSize      int
+	IPRateLimit            float64
+	IPRateBurst            int
+	TeamRateLimit          float64
+	TeamRateBurst          int
+	TrustForwardedFor      bool
+	TLSCertFile            string
+	TLSKeyFile             string
+	TLSMinVersion          string
+	TLSClientCAFile        string
+	AdminListenAddress     string
+	OptionsPath            string
+	InteractivePath        string
+	CommandsPath           string
+	TracingEndpoint        string
+	TracingSampleRatio     float64
+	Analytics              bool
+	AnalyticsFile          string
+	AnalyticsPrefixLength  int
+	AnalyticsMinCount      int
+	Apps                   []AppConfig // additional apps, read from the settings file
+	PrintConfig            bool
+	Report                 bool
+
+	sources map[string]string // layer each setting came from, by environment variable name
 }
 
 // CatalogEntry represents a catalog configuration entry
 type CatalogEntry struct {
-	ActionID string   `json:"actionId"`
-	Options  []Option `json:"options"`
+	ActionID       string              `json:"actionId"`
+	BlockID        string              `json:"blockId,omitempty"`
+	CallbackID     string              `json:"callbackId,omitempty"`
+	Options        []Option            `json:"options"`
+	DependsOn      string              `json:"dependsOn,omitempty"`
+	OptionsByValue map[string][]Option `json:"optionsByValue,omitempty"`
+	DefaultFilters []string            `json:"defaultFilters,omitempty"`
+	Sort           string              `json:"sort,omitempty"`
+	FoldValueCase  bool                `json:"foldValueCase,omitempty"`
+	Vars           map[string]string   `json:"vars,omitempty"`
+	Visibility     *Visibility         `json:"visibility,omitempty"`
+
+	route        route                   // compiled BlockID and CallbackID patterns, set by setCatalog
+	index        *optionIndex            // index of Options, set by setCatalog
+	indexByValue map[string]*optionIndex // indexes of OptionsByValue, set by setCatalog
+
+	foldDependsOnCase bool // whether DependsOn values are lowercased, set by setCatalog
 }
 
 // Option represents a single option in the catalog
 type Option struct {
-	Text  string `json:"text"`
-	Value string `json:"value"`
+	Text        string            `json:"text"`
+	Value       string            `json:"value"`
+	Description string            `json:"description,omitempty"`
+	Aliases     []string          `json:"aliases,omitempty"`
+	Keywords    []string          `json:"keywords,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Visibility  *Visibility       `json:"visibility,omitempty"`
+	Vars        map[string]string `json:"vars,omitempty"`
+	ValidFrom   *time.Time        `json:"validFrom,omitempty"`
+	ValidUntil  *time.Time        `json:"validUntil,omitempty"`
+	Weight      int               `json:"weight,omitempty"`
+	Pinned      bool              `json:"pinned,omitempty"`
+
+	searchKey       string   // folded text fields, set by setCatalog
+	tagKeys         []string // folded tags, set by setCatalog
+	searchKeysReady bool
 }
 
 // SlackRequest represents the incoming Slack request
 type SlackRequest struct {
-	Type     string `json:"type"`
-	ActionID string `json:"action_id"`
-	BlockID  string `json:"block_id"`
-	Value    string `json:"value"`
+	Type       string         `json:"type"`
+	ActionID   string         `json:"action_id"`
+	BlockID    string         `json:"block_id"`
+	Value      string         `json:"value"`
+	Name       string         `json:"name,omitempty"`
+	CallbackID string         `json:"callback_id,omitempty"`
+	Team       SlackTeam      `json:"team"`
+	User       SlackUser      `json:"user"`
+	Channel    SlackChannel   `json:"channel"`
+	Container  SlackContainer `json:"container"`
+	Actions    []SlackAction  `json:"actions,omitempty"`
+	View       *SlackView     `json:"view,omitempty"`
+}
+
+// SlackTeam represents the workspace a Slack request originates from
+type SlackTeam struct {
+	ID string `json:"id"`
+}
+
+// SlackUser represents the user who triggered a Slack request
+type SlackUser struct {
+	ID string `json:"id"`
+}
+
+// SlackChannel represents the channel a Slack request originates from
+type SlackChannel struct {
+	ID string `json:"id"`
+}
+
+// SlackContainer represents the surface (message or view) containing the interactive element
+type SlackContainer struct {
+	Type      string `json:"type"`
+	ChannelID string `json:"channel_id,omitempty"`
+	ViewID    string `json:"view_id,omitempty"`
+	MessageTS string `json:"message_ts,omitempty"`
+}
+
+// SlackAction represents a single action in a block_actions payload
+type SlackAction struct {
+	Type            string                `json:"type"`
+	ActionID        string                `json:"action_id"`
+	BlockID         string                `json:"block_id"`
+	SelectedOption  *SlackSelectedOption  `json:"selected_option,omitempty"`
+	SelectedOptions []SlackSelectedOption `json:"selected_options,omitempty"`
+}
+
+// SlackSelectedOption represents an option chosen by the user
+type SlackSelectedOption struct {
+	Value string `json:"value"`
+}
+
+// SlackView represents the modal view included in interaction payloads
+type SlackView struct {
+	ID         string         `json:"id"`
+	CallbackID string         `json:"callback_id,omitempty"`
+	State      SlackViewState `json:"state"`
+}
+
+// SlackViewState holds input values of a view, keyed by block_id and then action_id
+type SlackViewState struct {
+	Values map[string]map[string]SlackStateValue `json:"values"`
+}
+
+// SlackStateValue represents the current value of a single input in a view
+type SlackStateValue struct {
+	Type            string                `json:"type"`
+	Value           string                `json:"value,omitempty"`
+	SelectedOption  *SlackSelectedOption  `json:"selected_option,omitempty"`
+	SelectedOptions []SlackSelectedOption `json:"selected_options,omitempty"`
 }
 
 // SlackResponse represents the response sent back to Slack
@@ -51,8 +185,32 @@ type SlackResponse struct {
 
 // SlackOption represents a single option in the Slack response
 type SlackOption struct {
-	Text  SlackText `json:"text"`
-	Value string    `json:"value"`
+	Text        SlackText  `json:"text"`
+	Value       string     `json:"value"`
+	Description *SlackText `json:"description,omitempty"`
+}
+
+// SlackDialogResponse represents the response to a legacy dialog_suggestion request
+type SlackDialogResponse struct {
+	Options []SlackDialogOption `json:"options"`
+}
+
+// SlackDialogOption represents a single option in a legacy dialog select menu
+type SlackDialogOption struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// SlackMessageResponse represents the response to a legacy interactive_message options load
+type SlackMessageResponse struct {
+	Options []SlackMessageOption `json:"options"`
+}
+
+// SlackMessageOption represents a single option in a legacy message attachment menu
+type SlackMessageOption struct {
+	Text        string `json:"text"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
 }
 
 // SlackText represents the text field in a Slack option
@@ -61,180 +219,529 @@ type SlackText struct {
 	Text string `json:"text"`
 }
 
-var catalog []CatalogEntry
-
 func main() {
-	config := loadConfig()
+	config, err := loadConfig(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if config.PrintConfig {
+		printConfig(os.Stdout, config)
+		return
+	}
+	if config.Report {
+		if err := runAnalyticsReport(os.Stdout, config); err != nil {
+			log.Fatalf("Failed to report analytics: %v", err)
+		}
+		return
+	}
 
-	if err := loadCatalog(config.ConfigFile); err != nil {
-		log.Fatalf("Failed to load catalog: %v", err)
+	if config.ResponseCacheSize > 0 {
+		responses = newResponseCache(config.ResponseCacheSize)
+	}
+	if config.IPRateLimit > 0 {
+		ipLimits = newRateLimiter(config.IPRateLimit, config.IPRateBurst)
+	}
+	if config.TeamRateLimit > 0 {
+		teamLimits = newRateLimiter(config.TeamRateLimit, config.TeamRateBurst)
+	}
+	trustForwardedFor = config.TrustForwardedFor
+	if err := setupTracing(config); err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
 	}
 
-	http.HandleFunc("/", handleRequest(config.SlackSigningSecret))
+	apps, err := newSlackApps(config)
+	if err != nil {
+		log.Fatalf("Failed to configure apps: %v", err)
+	}
+	for _, app := range apps {
+		if err := app.catalog.load(app.config.ConfigFile); err != nil {
+			log.Fatalf("Failed to load the catalog of app '%s': %v", app.config.Name, err)
+		}
+	}
+	go reloadCatalogsOnSignal(apps)
 
-	log.Printf("Starting server on port %s", config.Port)
-	if err := http.ListenAndServe(":"+config.Port, nil); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	selections = newSelectionStore(config.SelectionsFile, defaultMaxSelectionUsers, defaultMaxSelectionsPerUser)
+	if err := selections.load(); err != nil {
+		log.Fatalf("Failed to load selections: %v", err)
 	}
-}
+	go selections.saveEvery(selectionsSaveInterval)
 
-// loadConfig loads configuration from environment variables
-func loadConfig() Config {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if config.Analytics {
+		analytics = newAnalyticsStore(config.AnalyticsFile, config.AnalyticsPrefixLength, config.AnalyticsMinCount)
+		if err := analytics.load(); err != nil {
+			log.Fatalf("Failed to load analytics: %v", err)
+		}
+		go analytics.saveEvery(analyticsSaveInterval)
+	} else if config.AnalyticsFile != "" {
+		log.Printf("Warning: ANALYTICS_FILE is set but analytics are disabled; set ANALYTICS=true to record them")
 	}
 
-	signingSecret := os.Getenv("SLACK_SIGNING_SECRET")
-	if signingSecret == "" {
-		log.Fatal("SLACK_SIGNING_SECRET environment variable is required")
+	appToken, err := newSecretSource(config.SlackAppToken, config.SlackAppTokenFile)
+	if err != nil {
+		log.Fatalf("Failed to load the app token: %v", err)
+	}
+
+	if appToken != nil {
+		client := newSocketModeClient(defaultCatalog, appToken, config.SocketModeURL)
+		if !servesHTTP(apps) {
+			// Without a signing secret there is no HTTP endpoint to serve
+			log.Printf("Starting Socket Mode client")
+			if err := client.run(context.Background()); err != nil {
+				log.Fatalf("Socket Mode client failed: %v", err)
+			}
+			return
+		}
+
+		go func() {
+			if err := client.run(context.Background()); err != nil {
+				log.Fatalf("Socket Mode client failed: %v", err)
+			}
+		}()
+	}
+
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
 	}
 
-	configFile := os.Getenv("CONFIG_FILE")
-	if configFile == "" {
-		configFile = "catalog.json"
+	admin := newAdminMux(apps)
+	if config.AdminListenAddress != "" {
+		go serveAdmin(config.AdminListenAddress, requireClientCert(config.TLSClientCAFile != "", admin), tlsConfig)
 	}
+	mux := newServeMux(apps, mainListenerAdmin(config, admin))
 
-	return Config{
-		Port:               port,
-		SlackSigningSecret: signingSecret,
-		ConfigFile:         configFile,
+	listener, err := listen(listenAddress(config), os.LookupEnv)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	server := &http.Server{
+		Handler:   mux,
+		TLSConfig: tlsConfig,
+	}
+	if tlsConfig != nil {
+		log.Printf("Starting TLS server on %s", listener.Addr())
+		err = server.ServeTLS(listener, "", "")
+	} else {
+		log.Printf("Starting server on %s", listener.Addr())
+		err = server.Serve(listener)
+	}
+	if err != nil {
+		log.Fatalf("Server failed: %v", err)
 	}
 }
 
-// loadCatalog loads the catalog from a JSON file
+// loadCatalog loads the catalog of the default app from a JSON file
 func loadCatalog(filename string) error {
+	return defaultCatalog.load(filename)
+}
+
+// load loads the catalog from a JSON file and makes it the active catalog
+func (s *catalogStore) load(filename string) (err error) {
+	_, span := startSpan(context.Background(), "catalog.load", attribute.String("catalog.file", filename))
+	defer func() { endSpan(span, err) }()
+
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return fmt.Errorf("reading catalog file: %w", err)
 	}
 
-	if err := json.Unmarshal(data, &catalog); err != nil {
+	file, err := parseCatalogFile(data)
+	if err != nil {
 		return fmt.Errorf("parsing catalog JSON: %w", err)
 	}
 
-	log.Printf("Loaded %d catalog entries", len(catalog))
-	for _, entry := range catalog {
+	if err := expandCatalog(&file); err != nil {
+		return fmt.Errorf("expanding catalog templates: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("catalog.entries", len(file.Entries)))
+	if err := s.set(file.Entries); err != nil {
+		return err
+	}
+
+	log.Printf("Loaded %d catalog entries from %s", len(file.Entries), filename)
+	for _, entry := range file.Entries {
 		log.Printf("  Action '%s': %d option(s)", entry.ActionID, len(entry.Options))
 	}
 	return nil
 }
 
-// handleRequest handles incoming Slack requests
-func handleRequest(signingSecret string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+// setCatalog validates and prepares catalog entries and makes them the active catalog of the default app
+func setCatalog(entries []CatalogEntry) error {
+	return defaultCatalog.set(entries)
+}
+
+// set validates and prepares catalog entries and makes them the active catalog
+func (s *catalogStore) set(entries []CatalogEntry) error {
+	now := time.Now()
+	folded := foldedActions(entries)
+	for i := range entries {
+		if err := normalizeEntry(i, &entries[i], folded); err != nil {
+			return err
 		}
 
-		body, err := io.ReadAll(r.Body)
+		r, err := compileRoute(entries[i])
 		if err != nil {
-			log.Printf("Error reading body: %v", err)
-			http.Error(w, "Bad request", http.StatusBadRequest)
-			return
+			return fmt.Errorf("entry %d (action '%s'): %w", i, entries[i].ActionID, err)
 		}
-		defer r.Body.Close()
+		entries[i].route = r
 
-		// Validate Slack signature
-		timestamp := r.Header.Get("X-Slack-Request-Timestamp")
-		signature := r.Header.Get("X-Slack-Signature")
-
-		if !verifySlackSignature(signingSecret, timestamp, body, signature) {
-			log.Printf("Invalid Slack signature")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
+		if !validSortOrders[entries[i].Sort] {
+			return fmt.Errorf("entry %d (action '%s'): unknown sort order '%s'", i, entries[i].ActionID, entries[i].Sort)
 		}
 
-		// Parse the request based on content type
-		var slackReq SlackRequest
-		contentType := r.Header.Get("Content-Type")
+		for _, filter := range entries[i].DefaultFilters {
+			if len(parseQuery(filter).Terms) > 0 {
+				return fmt.Errorf("entry %d (action '%s'): default filter '%s' is not a tag filter like '#tag' or '-#tag'", i, entries[i].ActionID, filter)
+			}
+		}
 
-		// Parse media type to handle charset and other parameters
-		mediaType, _, err := mime.ParseMediaType(contentType)
-		if err != nil {
-			// If we can't parse, fall back to simple string comparison
-			mediaType = strings.ToLower(strings.TrimSpace(contentType))
+		if err := checkTimeWindows(i, entries[i], now); err != nil {
+			return err
 		}
+	}
 
-		if mediaType == "application/x-www-form-urlencoded" {
-			// Parse form-encoded data
-			values, err := url.ParseQuery(string(body))
-			if err != nil {
-				log.Printf("Error parsing form data: %v", err)
-				http.Error(w, "Bad request", http.StatusBadRequest)
-				return
+	// Entries sharing an action ID must be distinguishable by precedence
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			if entries[i].ActionID == entries[j].ActionID && entries[i].route.ambiguousWith(entries[j].route) {
+				return fmt.Errorf("entries %d and %d (action '%s') have ambiguous blockId/callbackId rules", i, j, entries[i].ActionID)
 			}
+		}
+	}
 
-			// Extract and decode the payload field
-			payloadStr := values.Get("payload")
-			if payloadStr == "" {
-				log.Printf("Missing payload field in form data")
-				http.Error(w, "Bad request", http.StatusBadRequest)
-				return
-			}
+	s.active.Store(newCatalogIndex(entries))
+	responses.clear()
+	return nil
+}
 
-			// Decode JSON from payload
-			if err := json.Unmarshal([]byte(payloadStr), &slackReq); err != nil {
-				log.Printf("Error parsing payload JSON: %v", err)
-				http.Error(w, "Bad request", http.StatusBadRequest)
-				return
-			}
-		} else if mediaType == "application/json" || mediaType == "" {
-			// Handle direct JSON (backward compatibility)
-			// Empty content type is treated as JSON for backward compatibility
-			if err := json.Unmarshal(body, &slackReq); err != nil {
-				log.Printf("Error parsing request: %v", err)
-				http.Error(w, "Bad request", http.StatusBadRequest)
-				return
+// reloadCatalogsOnSignal reloads the catalog file of every app whenever the
+// process receives SIGHUP. If a new catalog is invalid, the current one stays active.
+func reloadCatalogsOnSignal(apps []*slackApp) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		log.Printf("Received SIGHUP, reloading catalogs")
+		for _, app := range apps {
+			if err := app.catalog.load(app.config.ConfigFile); err != nil {
+				log.Printf("Error reloading the catalog of app '%s', keeping the current one: %v", app.config.Name, err)
 			}
-		} else {
-			log.Printf("Unsupported content type: %s", contentType)
-			http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		}
+	}
+}
+
+// handleRequest handles incoming Slack requests, dispatching each payload to
+// the interaction handler registered for its type with the active catalog
+func handleRequest(catalog *catalogStore, signingSecret secretSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		slackReq, ok := readSlackRequest(w, r, signingSecret)
+		if !ok || !allowTeam(w, slackReq.Team.ID) {
+			return
+		}
+
+		ctx := r.Context()
+		trace.SpanFromContext(ctx).SetAttributes(requestAttributes(slackReq)...)
+		response, err := dispatchInteraction(ctx, catalog.current(), slackReq)
+		if err != nil {
+			log.Printf("Error handling %s interaction: %v", slackReq.Type, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		log.Printf("Received request for action_id: %s", slackReq.ActionID)
+		// A nil response is acknowledged with an empty 200
+		if response == nil {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
-		// Find matching catalog entry
-		var options []Option
-		for _, entry := range catalog {
-			if entry.ActionID == slackReq.ActionID {
-				options = entry.Options
-				break
+		w.Header().Set("Content-Type", "application/json")
+		if body, ok := response.(json.RawMessage); ok {
+			// Already encoded, and traced, by the handler or the response cache
+			if _, err := w.Write(body); err != nil {
+				log.Printf("Error writing response: %v", err)
 			}
+			return
 		}
 
-		// Filter options based on the query value (case-insensitive substring match)
-		query := strings.ToLower(slackReq.Value)
-		var filteredOptions []Option
-		for _, opt := range options {
-			if query == "" || strings.Contains(strings.ToLower(opt.Text), query) || strings.Contains(strings.ToLower(opt.Value), query) {
-				filteredOptions = append(filteredOptions, opt)
-			}
+		_, span := startSpan(ctx, "slack.encode")
+		defer span.End()
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Printf("Error encoding response: %v", err)
 		}
+	}
+}
+
+// handleBlockSuggestion returns the catalog options matching a block_suggestion
+// request. Encoded responses are served from the response cache when possible.
+func handleBlockSuggestion(ctx context.Context, c *catalogIndex, slackReq SlackRequest) (interface{}, error) {
+	log.Printf("Received request for action_id: %s", slackReq.ActionID)
+
+	matched := c.lookupEntry(ctx, slackReq)
+	if matched == nil {
+		return SlackResponse{Options: []SlackOption{}}, nil
+	}
+
+	now := time.Now()
+	key := newResponseCacheKey(c, matched, slackReq)
+	body, results, ok := responses.get(key, now)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("catalog.cache_hit", ok))
+	if ok {
+		analytics.recordSearch(slackReq.ActionID, slackReq.Value, results)
+		return json.RawMessage(body), nil
+	}
+
+	filteredOptions := truncateOptions(filterOptions(ctx, matched, slackReq))
+	analytics.recordSearch(slackReq.ActionID, slackReq.Value, len(filteredOptions))
+
+	// Build response
+	slackOptions := make([]SlackOption, len(filteredOptions))
+	for i, opt := range filteredOptions {
+		slackOptions[i] = toSlackOption(opt)
+	}
 
-		// Build response
-		slackOptions := make([]SlackOption, len(filteredOptions))
-		for i, opt := range filteredOptions {
-			slackOptions[i] = SlackOption{
-				Text: SlackText{
-					Type: "plain_text",
-					Text: opt.Text,
-				},
-				Value: opt.Value,
+	response := SlackResponse{
+		Options: slackOptions,
+	}
+	if responses == nil {
+		return response, nil
+	}
+
+	_, span := startSpan(ctx, "slack.encode")
+	body, err := json.Marshal(response)
+	endSpan(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("encoding response: %w", err)
+	}
+	responses.put(key, body, len(filteredOptions), responseExpiry(key.index, now))
+	return json.RawMessage(body), nil
+}
+
+// lookupOptions finds the catalog entry for a request and returns its options
+// filtered by the request's query, sorted, and ranked for the requesting user.
+// Pinned options always come first, whatever the search text, if they pass
+// the tag filters.
+func (c *catalogIndex) lookupOptions(ctx context.Context, slackReq SlackRequest) []Option {
+	matched := c.lookupEntry(ctx, slackReq)
+	if matched == nil {
+		return nil
+	}
+	options := filterOptions(ctx, matched, slackReq)
+	analytics.recordSearch(slackReq.ActionID, slackReq.Value, len(options))
+	return options
+}
+
+// lookupEntry is findEntry, traced as the catalog.lookup stage of a request
+func (c *catalogIndex) lookupEntry(ctx context.Context, slackReq SlackRequest) *CatalogEntry {
+	return c.traceLookup(ctx, func() *CatalogEntry { return c.findEntry(slackReq) })
+}
+
+// traceLookup runs find as the catalog.lookup stage of a request
+func (c *catalogIndex) traceLookup(ctx context.Context, find func() *CatalogEntry) *CatalogEntry {
+	_, span := startSpan(ctx, "catalog.lookup", attribute.Int64("catalog.version", int64(c.version)))
+	defer span.End()
+
+	matched := find()
+	span.SetAttributes(attribute.Bool("catalog.found", matched != nil))
+	return matched
+}
+
+// filterOptions is entryOptions, traced as the catalog.filter stage of a request
+func filterOptions(ctx context.Context, matched *CatalogEntry, slackReq SlackRequest) []Option {
+	_, span := startSpan(ctx, "catalog.filter")
+	defer span.End()
+
+	options := entryOptions(matched, slackReq)
+	span.SetAttributes(attribute.Int("catalog.options", len(options)))
+	return options
+}
+
+// entryOptions returns the options of a catalog entry matching a request, as described for lookupOptions
+func entryOptions(matched *CatalogEntry, slackReq SlackRequest) []Option {
+	// Filter options based on the query value (tag filters, then case-insensitive substring match),
+	// checking only the candidates the index could not rule out
+	now := time.Now()
+	query := parseQuery(slackReq.Value).withDefaults(parseQuery(strings.Join(matched.DefaultFilters, " ")))
+	scope := scopeOf(slackReq)
+	var pinnedOptions, filteredOptions []Option
+	for opt := range matched.indexFor(slackReq).candidates(query) {
+		if !opt.activeAt(now) || !opt.Visibility.allows(scope) {
+			continue
+		}
+		if opt.Pinned {
+			// Pinned options ignore the search text but not the tag filters
+			if query.matchesTags(opt) {
+				pinnedOptions = append(pinnedOptions, opt)
 			}
+		} else if query.matches(opt) {
+			filteredOptions = append(filteredOptions, opt)
 		}
+	}
 
-		response := SlackResponse{
-			Options: slackOptions,
+	var popularity map[string]int
+	if matched.Sort == sortPopularity {
+		popularity = selections.popularity(slackReq.ActionID)
+	}
+	sortOptions(pinnedOptions, matched.Sort, popularity)
+	sortOptions(filteredOptions, matched.Sort, popularity)
+
+	// Boost the options this user has picked recently and frequently
+	scores := selections.scores(slackReq.Team.ID, slackReq.User.ID, slackReq.ActionID, now)
+	return append(pinnedOptions, rankBySelections(filteredOptions, scores)...)
+}
+
+// truncateOptions limits options to the number Slack accepts in a single response
+func truncateOptions(options []Option) []Option {
+	if len(options) > maxSlackOptions {
+		return options[:maxSlackOptions]
+	}
+	return options
+}
+
+// findEntry returns the catalog entry serving a request, or nil if there is
+// none visible to the requester. When several entries share the action ID,
+// the visible one whose blockId/callbackId rules take precedence wins, so an
+// entry hidden from the requester falls back to a less specific one.
+func (c *catalogIndex) findEntry(slackReq SlackRequest) *CatalogEntry {
+	scope := scopeOf(slackReq)
+	var best *CatalogEntry
+	for _, entry := range c.byAction[slackReq.ActionID] {
+		if !entry.route.matches(slackReq) || !entry.Visibility.allows(scope) {
+			continue
+		}
+		if best == nil || entry.route.compare(best.route) > 0 {
+			best = entry
 		}
+	}
+	return best
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Printf("Error encoding response: %v", err)
+// readSlackRequest verifies and parses an incoming Slack request. On failure
+// it writes an error response and returns false.
+func readSlackRequest(w http.ResponseWriter, r *http.Request, signingSecret secretSource) (SlackRequest, bool) {
+	var slackReq SlackRequest
+	body, ok := readVerifiedBody(w, r, signingSecret)
+	if !ok {
+		return slackReq, false
+	}
+
+	_, span := startSpan(r.Context(), "slack.parse")
+	defer span.End()
+
+	// Parse the request based on content type
+	contentType := r.Header.Get("Content-Type")
+	mediaType := parseMediaType(contentType)
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		// Parse form-encoded data
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			log.Printf("Error parsing form data: %v", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return slackReq, false
+		}
+
+		// Extract and decode the payload field
+		payloadStr := values.Get("payload")
+		if payloadStr == "" {
+			log.Printf("Missing payload field in form data")
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return slackReq, false
+		}
+
+		// Decode JSON from payload
+		if err := json.Unmarshal([]byte(payloadStr), &slackReq); err != nil {
+			log.Printf("Error parsing payload JSON: %v", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return slackReq, false
+		}
+	} else if mediaType == "application/json" || mediaType == "" {
+		// Handle direct JSON (backward compatibility)
+		// Empty content type is treated as JSON for backward compatibility
+		if err := json.Unmarshal(body, &slackReq); err != nil {
+			log.Printf("Error parsing request: %v", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return slackReq, false
+		}
+	} else {
+		log.Printf("Unsupported content type: %s", contentType)
+		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return slackReq, false
+	}
+
+	return slackReq, true
+}
+
+// readVerifiedBody reads the body of a POST request and verifies its Slack
+// signature. On failure it writes an error response and returns false.
+func readVerifiedBody(w http.ResponseWriter, r *http.Request, signingSecret secretSource) ([]byte, bool) {
+	_, span := startSpan(r.Context(), "slack.verify")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading body: %v", err)
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	// Validate Slack signature
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	signature := r.Header.Get("X-Slack-Signature")
+
+	if !verifySlackSignature(signingSecret(), timestamp, body, signature) {
+		log.Printf("Invalid Slack signature")
+		span.SetStatus(codes.Error, "invalid Slack signature")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return body, true
+}
+
+// parseMediaType returns the media type of a Content-Type header without its parameters
+func parseMediaType(contentType string) string {
+	// Parse media type to handle charset and other parameters
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// If we can't parse, fall back to simple string comparison
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+// matchesText reports whether an option matches a folded search term (see
+// foldSearchText). The text, value, description, aliases and keywords are all
+// searched; tags are not, as they are matched by the tag filters of a Query instead.
+func matchesText(opt Option, query string) bool {
+	key, _ := opt.searchKeys()
+	return strings.Contains(key, query)
+}
+
+// toSlackOption converts a catalog option into its Slack representation
+func toSlackOption(opt Option) SlackOption {
+	slackOpt := SlackOption{
+		Text: SlackText{
+			Type: "plain_text",
+			Text: opt.Text,
+		},
+		Value: opt.Value,
+	}
+	if opt.Description != "" {
+		slackOpt.Description = &SlackText{
+			Type: "plain_text",
+			Text: opt.Description,
 		}
 	}
+	return slackOpt
 }
 
 // verifySlackSignature verifies the Slack request signature
@@ -15,9 +15,16 @@ import (
 	"time"
 )
 
+// setTestCatalog installs a catalog for tests, panicking if it is invalid
+func setTestCatalog(entries []CatalogEntry) {
+	if err := setCatalog(entries); err != nil {
+		panic(err)
+	}
+}
+
 // setupTestCatalog initializes a test catalog
 func setupTestCatalog() {
-	catalog = []CatalogEntry{
+	setTestCatalog([]CatalogEntry{
 		{
 			ActionID: "test_action",
 			Options: []Option{
@@ -25,12 +32,12 @@ func setupTestCatalog() {
 				{Text: "Option 2", Value: "opt2"},
 			},
 		},
-	}
+	})
 }
 
 // setupTestCatalogWithMoreOptions initializes a test catalog with more options for filtering tests
 func setupTestCatalogWithMoreOptions() {
-	catalog = []CatalogEntry{
+	setTestCatalog([]CatalogEntry{
 		{
 			ActionID: "test_action",
 			Options: []Option{
@@ -41,7 +48,7 @@ func setupTestCatalogWithMoreOptions() {
 				{Text: "Gateway", Value: "Gateway"},
 			},
 		},
-	}
+	})
 }
 
 // generateTestSignature generates a valid Slack signature for testing
@@ -52,6 +59,48 @@ func generateTestSignature(secret, timestamp string, body []byte) string {
 	return "v0=" + hex.EncodeToString(mac.Sum(nil))
 }
 
+// sendTestRequest signs and sends a JSON payload to handleRequest and returns the recorded response
+func sendTestRequest(t *testing.T, secret string, payload interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	return sendSignedRequest(t, handleRequest(defaultCatalog, staticSecret(secret)), secret, payload)
+}
+
+// sendSignedRequest signs and sends a JSON payload to the given handler and returns the recorded response
+func sendSignedRequest(t *testing.T, handler http.Handler, secret string, payload interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	jsonBody, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", generateTestSignature(secret, timestamp, jsonBody))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// decodeTestResponse decodes a SlackResponse from a recorded response
+func decodeTestResponse(t *testing.T, rr *httptest.ResponseRecorder) SlackResponse {
+	t.Helper()
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response SlackResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return response
+}
+
 func TestHandleRequest_FormEncoded(t *testing.T) {
 	setupTestCatalog()
 	secret := "test-secret"
@@ -89,7 +138,7 @@ func TestHandleRequest_FormEncoded(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
@@ -149,7 +198,7 @@ func TestHandleRequest_DirectJSON(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
@@ -192,7 +241,7 @@ func TestHandleRequest_MissingPayload(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code - should be 400 Bad Request
@@ -224,7 +273,7 @@ func TestHandleRequest_InvalidJSON(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code - should be 400 Bad Request
@@ -253,7 +302,7 @@ func TestHandleRequest_UnsupportedContentType(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code - should be 415 Unsupported Media Type
@@ -299,7 +348,7 @@ func TestHandleRequest_FormEncodedWithCharset(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
@@ -351,7 +400,7 @@ func TestHandleRequest_FilterByValue_EmptyQuery(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
@@ -403,7 +452,7 @@ func TestHandleRequest_FilterByValue_MatchingText(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
@@ -474,7 +523,7 @@ func TestHandleRequest_FilterByValue_CaseInsensitive(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
@@ -545,7 +594,7 @@ func TestHandleRequest_FilterByValue_NoMatch(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
@@ -597,7 +646,7 @@ func TestHandleRequest_FilterByValue_MatchByValue(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call handler
-	handler := handleRequest(secret)
+	handler := handleRequest(defaultCatalog, staticSecret(secret))
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
@@ -620,3 +669,139 @@ func TestHandleRequest_FilterByValue_MatchByValue(t *testing.T) {
 		t.Errorf("Expected 'Poppit', got '%s'", response.Options[0].Text.Text)
 	}
 }
+
+// setupTestCatalogWithMetadata initializes a test catalog whose options carry descriptions, aliases, keywords and tags
+func setupTestCatalogWithMetadata() {
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID: "test_action",
+			Options: []Option{
+				{
+					Text:        "InnerGate",
+					Value:       "InnerGate",
+					Description: "Internal API gateway",
+					Aliases:     []string{"ig"},
+					Keywords:    []string{"proxy", "ingress"},
+					Tags:        []string{"backend"},
+				},
+				{Text: "OctoSlack", Value: "OctoSlack", Tags: []string{"bots"}},
+				{Text: "Poppit", Value: "Poppit", Keywords: []string{"notifications"}},
+			},
+		},
+	})
+}
+
+func TestHandleRequest_FilterByMetadata(t *testing.T) {
+	setupTestCatalogWithMetadata()
+	secret := "test-secret"
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "description", query: "gateway", expected: []string{"InnerGate"}},
+		{name: "alias", query: "IG", expected: []string{"InnerGate"}},
+		{name: "keyword", query: "ingress", expected: []string{"InnerGate"}},
+		{name: "keyword on other option", query: "notif", expected: []string{"Poppit"}},
+		{name: "tags are not searched", query: "backend", expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := sendTestRequest(t, secret, SlackRequest{
+				Type:     "block_suggestion",
+				ActionID: "test_action",
+				Value:    tt.query,
+			})
+			response := decodeTestResponse(t, rr)
+
+			if len(response.Options) != len(tt.expected) {
+				t.Fatalf("Expected %d options, got %d", len(tt.expected), len(response.Options))
+			}
+			for i, text := range tt.expected {
+				if response.Options[i].Text.Text != text {
+					t.Errorf("Expected option %d to be '%s', got '%s'", i, text, response.Options[i].Text.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleRequest_OptionDescription(t *testing.T) {
+	setupTestCatalogWithMetadata()
+	secret := "test-secret"
+
+	rr := sendTestRequest(t, secret, SlackRequest{
+		Type:     "block_suggestion",
+		ActionID: "test_action",
+	})
+	response := decodeTestResponse(t, rr)
+
+	if len(response.Options) != 3 {
+		t.Fatalf("Expected 3 options, got %d", len(response.Options))
+	}
+
+	// Options with a description render it as a plain_text object
+	description := response.Options[0].Description
+	if description == nil {
+		t.Fatal("Expected first option to have a description")
+	}
+	if description.Type != "plain_text" || description.Text != "Internal API gateway" {
+		t.Errorf("Unexpected description: %+v", *description)
+	}
+
+	// Options without a description omit the field entirely
+	if response.Options[1].Description != nil {
+		t.Errorf("Expected second option to have no description, got %+v", *response.Options[1].Description)
+	}
+}
+
+func TestHandleRequest_TagFilters(t *testing.T) {
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID:       "test_action",
+			DefaultFilters: []string{"-#archived"},
+			Options: []Option{
+				{Text: "InnerGate", Value: "InnerGate", Tags: []string{"lang:go", "backend"}},
+				{Text: "OctoSlack", Value: "OctoSlack", Tags: []string{"lang:go", "bots"}},
+				{Text: "SlackLiner", Value: "SlackLiner", Tags: []string{"lang:python", "bots"}},
+				{Text: "OldSlack", Value: "OldSlack", Tags: []string{"lang:go", "archived"}},
+			},
+		},
+	})
+	secret := "test-secret"
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "default filter hides archived", query: "", expected: []string{"InnerGate", "OctoSlack", "SlackLiner"}},
+		{name: "facet", query: "lang:go", expected: []string{"InnerGate", "OctoSlack"}},
+		{name: "facet with text", query: "lang:go slack", expected: []string{"OctoSlack"}},
+		{name: "hash tag", query: "#bots", expected: []string{"OctoSlack", "SlackLiner"}},
+		{name: "negated tag", query: "-#bots", expected: []string{"InnerGate"}},
+		{name: "explicit tag overrides default", query: "#archived", expected: []string{"OldSlack"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := sendTestRequest(t, secret, SlackRequest{
+				Type:     "block_suggestion",
+				ActionID: "test_action",
+				Value:    tt.query,
+			})
+			response := decodeTestResponse(t, rr)
+
+			if len(response.Options) != len(tt.expected) {
+				t.Fatalf("Expected %d options, got %d", len(tt.expected), len(response.Options))
+			}
+			for i, text := range tt.expected {
+				if response.Options[i].Text.Text != text {
+					t.Errorf("Expected option %d to be '%s', got '%s'", i, text, response.Options[i].Text.Text)
+				}
+			}
+		})
+	}
+}
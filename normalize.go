@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeText trims surrounding whitespace and converts s to Unicode NFC
+func normalizeText(s string) string {
+	return norm.NFC.String(strings.TrimSpace(s))
+}
+
+// normalizeAll normalizes every string in a slice in place
+func normalizeAll(values []string) {
+	for i := range values {
+		values[i] = normalizeText(values[i])
+	}
+}
+
+// normalizeOption normalizes the text fields of an option, lowercasing its
+// value when foldValueCase is set
+func normalizeOption(opt *Option, foldValueCase bool) {
+	opt.Text = normalizeText(opt.Text)
+	opt.Value = normalizeText(opt.Value)
+	if foldValueCase {
+		opt.Value = strings.ToLower(opt.Value)
+	}
+	opt.Description = normalizeText(opt.Description)
+	normalizeAll(opt.Aliases)
+	normalizeAll(opt.Keywords)
+	normalizeAll(opt.Tags)
+}
+
+// foldedActions returns the action IDs of the entries that lowercase their
+// option values
+func foldedActions(entries []CatalogEntry) map[string]bool {
+	folded := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.FoldValueCase {
+			folded[normalizeText(entry.ActionID)] = true
+		}
+	}
+	return folded
+}
+
+// dependentKey normalizes an optionsByValue key, or an input value looked up
+// in them, the way the input's option values are normalized
+func dependentKey(value string, foldCase bool) string {
+	value = normalizeText(value)
+	if foldCase {
+		value = strings.ToLower(value)
+	}
+	return value
+}
+
+// normalizeEntry normalizes the options of an entry and removes options
+// whose value duplicates an earlier one, logging a warning for each. The
+// optionsByValue keys are lowercased when the entry the input depends on
+// lowercases its values, as listed in folded; keys that become equal are an
+// error.
+func normalizeEntry(index int, entry *CatalogEntry, folded map[string]bool) error {
+	entry.ActionID = normalizeText(entry.ActionID)
+	context := fmt.Sprintf("entry %d (action '%s')", index, entry.ActionID)
+
+	entry.Options = dedupeOptions(context, entry.Options, entry.FoldValueCase)
+	if entry.OptionsByValue != nil {
+		entry.foldDependsOnCase = folded[normalizeText(entry.DependsOn)]
+		byValue := make(map[string][]Option, len(entry.OptionsByValue))
+		original := make(map[string]string, len(entry.OptionsByValue))
+		for _, key := range slices.Sorted(maps.Keys(entry.OptionsByValue)) {
+			normalized := dependentKey(key, entry.foldDependsOnCase)
+			if previous, ok := original[normalized]; ok {
+				return fmt.Errorf("%s: optionsByValue keys %q and %q are both normalized to %q", context, previous, key, normalized)
+			}
+			original[normalized] = key
+			byValue[normalized] = dedupeOptions(fmt.Sprintf("%s optionsByValue[%q]", context, normalized), entry.OptionsByValue[key], entry.FoldValueCase)
+		}
+		entry.OptionsByValue = byValue
+	}
+	return nil
+}
+
+// dedupeOptions normalizes options and keeps only the first option with each value
+func dedupeOptions(context string, options []Option, foldValueCase bool) []Option {
+	if options == nil {
+		return nil
+	}
+
+	firstIndex := make(map[string]int, len(options))
+	deduped := make([]Option, 0, len(options))
+	for i, opt := range options {
+		normalizeOption(&opt, foldValueCase)
+		prepareSearchKeys(&opt)
+		if first, ok := firstIndex[opt.Value]; ok {
+			log.Printf("Warning: %s: option %d ('%s') duplicates the value '%s' of option %d ('%s'), ignoring it",
+				context, i, opt.Text, opt.Value, first, options[first].Text)
+			continue
+		}
+		firstIndex[opt.Value] = i
+		deduped = append(deduped, opt)
+	}
+	return deduped
+}
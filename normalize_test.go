@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	// "e" followed by a combining acute accent composes to "é" in NFC
+	if got := normalizeText("  Cafe\u0301 \n"); got != "Caf\u00e9" {
+		t.Errorf("Expected 'Caf\\u00e9', got %q", got)
+	}
+}
+
+func TestSetCatalog_NormalizesAndDedupesOptions(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID:      " repos ",
+			FoldValueCase: true,
+			Options: []Option{
+				{Text: " InnerGate ", Value: "InnerGate", Aliases: []string{" ig "}},
+				{Text: "OctoSlack", Value: "OctoSlack"},
+				{Text: "InnerGate (again)", Value: " innergate "},
+			},
+			OptionsByValue: map[string][]Option{
+				" key ": {{Text: "A", Value: "a"}, {Text: "A2", Value: "A"}},
+			},
+		},
+	})
+
+	entry := currentCatalog().entries[0]
+	if entry.ActionID != "repos" {
+		t.Errorf("Expected action ID to be trimmed, got %q", entry.ActionID)
+	}
+	if got := optionValues(entry.Options); !reflect.DeepEqual(got, []string{"innergate", "octoslack"}) {
+		t.Errorf("Unexpected values: %v", got)
+	}
+	if entry.Options[0].Text != "InnerGate" || entry.Options[0].Aliases[0] != "ig" {
+		t.Errorf("Expected text fields to be trimmed, got %+v", entry.Options[0])
+	}
+	if got := optionValues(entry.OptionsByValue["key"]); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Unexpected dependent values: %v", got)
+	}
+
+	for _, expected := range []string{
+		"entry 0 (action 'repos'): option 2 ('InnerGate (again)') duplicates the value 'innergate' of option 0",
+		`optionsByValue["key"]: option 1 ('A2') duplicates the value 'a' of option 0`,
+	} {
+		if !strings.Contains(logs.String(), expected) {
+			t.Errorf("Expected warning %q, got logs: %s", expected, logs.String())
+		}
+	}
+}
+
+func TestSetCatalog_RejectsCollidingDependentKeys(t *testing.T) {
+	err := setCatalog([]CatalogEntry{
+		{
+			ActionID:  "environment",
+			DependsOn: "repo",
+			OptionsByValue: map[string][]Option{
+				"InnerGate":  {{Text: "Staging", Value: "staging"}},
+				" InnerGate": {{Text: "Production", Value: "prod"}},
+			},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), `optionsByValue keys " InnerGate" and "InnerGate" are both normalized to "InnerGate"`) {
+		t.Errorf("Expected an error for colliding keys, got %v", err)
+	}
+}
+
+func TestSetCatalog_KeepsValueCaseByDefault(t *testing.T) {
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID: "repos",
+			Options:  []Option{{Text: "Upper", Value: "Repo"}, {Text: "Lower", Value: "repo"}},
+		},
+	})
+
+	if got := optionValues(currentCatalog().entries[0].Options); !reflect.DeepEqual(got, []string{"Repo", "repo"}) {
+		t.Errorf("Unexpected values: %v", got)
+	}
+}
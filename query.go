@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+)
+
+// Query represents a parsed search query.
+//
+// The query syntax is a whitespace-separated list of terms:
+//
+//	deploy        free text, matched against option text, value, description, aliases and keywords
+//	#backend      option must carry the tag "backend"
+//	lang:go       option must carry the tag "lang:go"
+//	-#archived    option must not carry the tag "archived"
+//	-lang:go      option must not carry the tag "lang:go"
+//
+// All terms must match for an option to be included.
+type Query struct {
+	Terms       []string
+	IncludeTags []string
+	ExcludeTags []string
+}
+
+// parseQuery parses a raw query string into a Query. Terms and tags are
+// folded for accent- and case-insensitive matching.
+func parseQuery(raw string) Query {
+	var q Query
+	for _, field := range strings.Fields(foldSearchText(raw)) {
+		negated := false
+		token := field
+		if strings.HasPrefix(token, "-") && len(token) > 1 {
+			negated = true
+			token = token[1:]
+		}
+
+		tag, ok := parseTagToken(token)
+		switch {
+		case ok && negated:
+			q.ExcludeTags = append(q.ExcludeTags, tag)
+		case ok:
+			q.IncludeTags = append(q.IncludeTags, tag)
+		default:
+			// Not a tag filter, so the whole field (including any leading dash) is free text
+			q.Terms = append(q.Terms, field)
+		}
+	}
+	return q
+}
+
+// parseTagToken recognizes "#tag" and "key:value" tokens and returns the tag they refer to
+func parseTagToken(token string) (string, bool) {
+	if strings.HasPrefix(token, "#") {
+		if len(token) == 1 {
+			return "", false
+		}
+		return token[1:], true
+	}
+
+	key, value, found := strings.Cut(token, ":")
+	if !found || !isFacetKey(key) || value == "" || strings.HasPrefix(value, "/") {
+		// A leading slash in the value means this is most likely a URL, not a facet
+		return "", false
+	}
+	return token, true
+}
+
+// isFacetKey reports whether s is a valid facet key: a letter followed by letters, digits, '_' or '-'
+func isFacetKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		isLetter := r >= 'a' && r <= 'z'
+		isDigit := r >= '0' && r <= '9'
+		if i == 0 && !isLetter {
+			return false
+		}
+		if !isLetter && !isDigit && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// withDefaults returns a copy of q with the tag filters of defaults added.
+// A default filter is skipped when q already mentions the same tag, so a user
+// can override an entry's defaults, e.g. "#archived" overrides "-#archived".
+func (q Query) withDefaults(defaults Query) Query {
+	result := Query{
+		Terms:       q.Terms,
+		IncludeTags: append([]string(nil), q.IncludeTags...),
+		ExcludeTags: append([]string(nil), q.ExcludeTags...),
+	}
+	for _, tag := range defaults.IncludeTags {
+		if !q.mentionsTag(tag) {
+			result.IncludeTags = append(result.IncludeTags, tag)
+		}
+	}
+	for _, tag := range defaults.ExcludeTags {
+		if !q.mentionsTag(tag) {
+			result.ExcludeTags = append(result.ExcludeTags, tag)
+		}
+	}
+	return result
+}
+
+// mentionsTag reports whether the query includes or excludes the given tag
+func (q Query) mentionsTag(tag string) bool {
+	return containsString(q.IncludeTags, tag) || containsString(q.ExcludeTags, tag)
+}
+
+// matches reports whether an option satisfies every tag filter and text term of the query
+func (q Query) matches(opt Option) bool {
+	if !q.matchesTags(opt) {
+		return false
+	}
+	for _, term := range q.Terms {
+		if !matchesText(opt, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesTags reports whether an option satisfies every tag filter of the query
+func (q Query) matchesTags(opt Option) bool {
+	_, tagKeys := opt.searchKeys()
+	for _, tag := range q.IncludeTags {
+		if !containsString(tagKeys, tag) {
+			return false
+		}
+	}
+	for _, tag := range q.ExcludeTags {
+		if containsString(tagKeys, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
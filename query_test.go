@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected Query
+	}{
+		{name: "empty", raw: "", expected: Query{}},
+		{name: "single term", raw: "Deploy", expected: Query{Terms: []string{"deploy"}}},
+		{name: "multiple terms", raw: "  octo   slack ", expected: Query{Terms: []string{"octo", "slack"}}},
+		{name: "hash tag", raw: "#Backend", expected: Query{IncludeTags: []string{"backend"}}},
+		{name: "facet", raw: "lang:go deploy", expected: Query{Terms: []string{"deploy"}, IncludeTags: []string{"lang:go"}}},
+		{name: "negated hash tag", raw: "-#archived", expected: Query{ExcludeTags: []string{"archived"}}},
+		{name: "negated facet", raw: "-lang:go", expected: Query{ExcludeTags: []string{"lang:go"}}},
+		{name: "lone hash is text", raw: "#", expected: Query{Terms: []string{"#"}}},
+		{name: "lone dash is text", raw: "-", expected: Query{Terms: []string{"-"}}},
+		{name: "dashed word is text", raw: "-foo", expected: Query{Terms: []string{"-foo"}}},
+		{name: "url is text", raw: "https://github.com", expected: Query{Terms: []string{"https://github.com"}}},
+		{name: "empty facet value is text", raw: "lang:", expected: Query{Terms: []string{"lang:"}}},
+		{name: "invalid facet key is text", raw: "1x:go", expected: Query{Terms: []string{"1x:go"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseQuery(tt.raw)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseQuery(%q) = %+v, want %+v", tt.raw, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQuery_WithDefaults(t *testing.T) {
+	defaults := parseQuery("-#archived #active")
+
+	// Defaults are added when the query does not mention the tag
+	got := parseQuery("deploy").withDefaults(defaults)
+	expected := Query{
+		Terms:       []string{"deploy"},
+		IncludeTags: []string{"active"},
+		ExcludeTags: []string{"archived"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	// Mentioning a tag overrides the default for that tag
+	got = parseQuery("#archived").withDefaults(defaults)
+	expected = Query{
+		IncludeTags: []string{"archived", "active"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestQuery_Matches(t *testing.T) {
+	opt := Option{
+		Text:  "DeployBot",
+		Value: "deploy-bot",
+		Tags:  []string{"Lang:Go", "backend"},
+	}
+
+	tests := []struct {
+		raw      string
+		expected bool
+	}{
+		{raw: "", expected: true},
+		{raw: "deploy", expected: true},
+		{raw: "deploy bot", expected: true},
+		{raw: "deploy slack", expected: false},
+		{raw: "lang:go", expected: true},
+		{raw: "lang:rust", expected: false},
+		{raw: "#backend deploy", expected: true},
+		{raw: "#frontend deploy", expected: false},
+		{raw: "-#backend", expected: false},
+		{raw: "-#archived deploy", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if got := parseQuery(tt.raw).matches(opt); got != tt.expected {
+				t.Errorf("parseQuery(%q).matches() = %v, want %v", tt.raw, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetCatalog_RejectsFreeTextDefaultFilters(t *testing.T) {
+	err := setCatalog([]CatalogEntry{{ActionID: "test_action", DefaultFilters: []string{"-#archived", "archived"}}})
+	if err == nil || !strings.Contains(err.Error(), "default filter 'archived' is not a tag filter") {
+		t.Errorf("Expected a default filter error, got %v", err)
+	}
+
+	if err := setCatalog([]CatalogEntry{{ActionID: "test_action", DefaultFilters: []string{"-#archived", "#lang:go"}}}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"container/list"
+	"expvar"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRateLimitKeys bounds how many buckets a rate limiter tracks; beyond it
+// the least recently used bucket is dropped
+const maxRateLimitKeys = 10000
+
+// rateLimitStats exposes the number of throttled requests at /debug/vars
+var rateLimitStats = expvar.NewMap("rateLimit")
+
+// tokenBucket holds the tokens left for a single key
+type tokenBucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a bounded, least-recently-used set of token buckets, one per
+// key, each refilling at rate tokens per second up to burst tokens
+type rateLimiter struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	capacity int
+	buckets  map[string]*list.Element
+	order    *list.List // of *tokenBucket, most recently used first
+}
+
+var (
+	// ipLimits limits requests per client IP before their signature is
+	// verified; nil disables it
+	ipLimits *rateLimiter
+	// teamLimits limits verified requests per Slack team; nil disables it
+	teamLimits *rateLimiter
+	// trustForwardedFor takes the client IP from the X-Forwarded-For header
+	// set by a reverse proxy instead of the connection's address
+	trustForwardedFor bool
+)
+
+// newRateLimiter creates a rate limiter allowing rate requests per second per
+// key with bursts of up to burst requests. A burst below one is raised to the
+// rate, rounded up.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	b := float64(burst)
+	if b < 1 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &rateLimiter{
+		rate:     rate,
+		burst:    b,
+		capacity: maxRateLimitKeys,
+		buckets:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// allow takes a token from the bucket of a key. If the bucket is empty it
+// returns false and how long until a token is available.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var bucket *tokenBucket
+	if elem, ok := l.buckets[key]; ok {
+		l.order.MoveToFront(elem)
+		bucket = elem.Value.(*tokenBucket)
+	} else {
+		bucket = &tokenBucket{key: key, tokens: l.burst, last: now}
+		l.buckets[key] = l.order.PushFront(bucket)
+		if l.order.Len() > l.capacity {
+			oldest := l.order.Remove(l.order.Back()).(*tokenBucket)
+			delete(l.buckets, oldest.key)
+		}
+	}
+
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed.Seconds()*l.rate)
+		bucket.last = now
+	}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// limitByIP rejects requests from client IPs that exceed ipLimits, before
+// any work is done to verify them
+func limitByIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		if ok, retryAfter := ipLimits.allow(ip, time.Now()); !ok {
+			log.Printf("Rate limit exceeded for IP %s", ip)
+			rateLimitStats.Add("ipThrottled", 1)
+			tooManyRequests(w, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowTeam checks a verified request against teamLimits. If the team is over
+// its limit, it writes a 429 response and returns false.
+func allowTeam(w http.ResponseWriter, teamID string) bool {
+	if teamID == "" {
+		return true
+	}
+	if ok, retryAfter := teamLimits.allow(teamID, time.Now()); !ok {
+		log.Printf("Rate limit exceeded for team %s", teamID)
+		rateLimitStats.Add("teamThrottled", 1)
+		tooManyRequests(w, retryAfter)
+		return false
+	}
+	return true
+}
+
+// clientIP returns the IP address a request comes from. When
+// trustForwardedFor is set, the last address in X-Forwarded-For (the one
+// added by the nearest proxy) is used.
+func clientIP(r *http.Request) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			addrs := strings.Split(forwarded, ",")
+			return strings.TrimSpace(addrs[len(addrs)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tooManyRequests writes a 429 response telling the client when to retry
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+}
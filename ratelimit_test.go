@@ -0,0 +1,176 @@
+package main
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// rateLimitStat returns the current value of a rate limit counter
+func rateLimitStat(name string) int64 {
+	if v, ok := rateLimitStats.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter := newRateLimiter(2, 2)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow("T1", now); !ok {
+			t.Fatalf("Expected request %d to be allowed within the burst", i+1)
+		}
+	}
+
+	ok, retryAfter := limiter.allow("T1", now)
+	if ok {
+		t.Fatal("Expected the request beyond the burst to be throttled")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("Expected to retry after 500ms, got %v", retryAfter)
+	}
+
+	if ok, _ := limiter.allow("T2", now); !ok {
+		t.Error("Expected another key to have its own bucket")
+	}
+	if ok, _ := limiter.allow("T1", now.Add(500*time.Millisecond)); !ok {
+		t.Error("Expected a token to be available after refilling")
+	}
+}
+
+func TestRateLimiter_DefaultBurst(t *testing.T) {
+	limiter := newRateLimiter(2.5, 0)
+	if limiter.burst != 3 {
+		t.Errorf("Expected the burst to default to the rounded up rate, got %v", limiter.burst)
+	}
+}
+
+func TestRateLimiter_EvictsLeastRecentlyUsed(t *testing.T) {
+	limiter := newRateLimiter(1, 1)
+	limiter.capacity = 2
+	now := time.Now()
+	limiter.allow("a", now)
+	limiter.allow("b", now)
+	limiter.allow("a", now)
+	limiter.allow("c", now)
+
+	if len(limiter.buckets) != 2 || limiter.order.Len() != 2 {
+		t.Errorf("Expected 2 buckets, got %d", len(limiter.buckets))
+	}
+	if _, ok := limiter.buckets["b"]; ok {
+		t.Error("Expected the least recently used bucket to be dropped")
+	}
+	if ok, _ := limiter.allow("a", now); ok {
+		t.Error("Expected the recently used bucket to be kept empty")
+	}
+}
+
+func TestLimitByIP(t *testing.T) {
+	ipLimits = newRateLimiter(1, 1)
+	defer func() { ipLimits = nil }()
+
+	handler := limitByIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	throttled := rateLimitStat("ipThrottled")
+	if rr := send("192.0.2.1:1234"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected the first request to be allowed, got %d", rr.Code)
+	}
+
+	rr := send("192.0.2.1:5678")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", got)
+	}
+	if got := rateLimitStat("ipThrottled") - throttled; got != 1 {
+		t.Errorf("Expected 1 throttled request, got %d", got)
+	}
+
+	if rr := send("192.0.2.2:1234"); rr.Code != http.StatusOK {
+		t.Errorf("Expected another IP to be allowed, got %d", rr.Code)
+	}
+}
+
+func TestHandleRequest_TeamRateLimit(t *testing.T) {
+	setupTestCatalog()
+	teamLimits = newRateLimiter(1, 1)
+	defer func() { teamLimits = nil }()
+
+	req := SlackRequest{Type: "block_suggestion", ActionID: "test_action", Team: SlackTeam{ID: "T1"}}
+	throttled := rateLimitStat("teamThrottled")
+
+	if rr := sendTestRequest(t, "test-secret", req); rr.Code != http.StatusOK {
+		t.Fatalf("Expected the first request to be allowed, got %d", rr.Code)
+	}
+	if rr := sendTestRequest(t, "test-secret", req); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", rr.Code)
+	}
+	if got := rateLimitStat("teamThrottled") - throttled; got != 1 {
+		t.Errorf("Expected 1 throttled request, got %d", got)
+	}
+
+	req.Team.ID = "T2"
+	if rr := sendTestRequest(t, "test-secret", req); rr.Code != http.StatusOK {
+		t.Errorf("Expected another team to be allowed, got %d", rr.Code)
+	}
+}
+
+func TestHandleRequest_TeamRateLimitAfterVerification(t *testing.T) {
+	setupTestCatalog()
+	teamLimits = newRateLimiter(1, 1)
+	defer func() { teamLimits = nil }()
+
+	// Requests with a bad signature must not use up the team's tokens
+	req := SlackRequest{Type: "block_suggestion", ActionID: "test_action", Team: SlackTeam{ID: "T1"}}
+	if rr := sendSignedRequest(t, handleRequest(defaultCatalog, staticSecret("test-secret")), "wrong-secret", req); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", rr.Code)
+	}
+	if rr := sendTestRequest(t, "test-secret", req); rr.Code != http.StatusOK {
+		t.Errorf("Expected the verified request to be allowed, got %d", rr.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trust     bool
+		forwarded string
+		expected  string
+	}{
+		{name: "remote address", expected: "192.0.2.1"},
+		{name: "untrusted header ignored", forwarded: "203.0.113.9", expected: "192.0.2.1"},
+		{name: "trusted header", trust: true, forwarded: "203.0.113.9", expected: "203.0.113.9"},
+		{name: "nearest proxy wins", trust: true, forwarded: "198.51.100.7, 203.0.113.9", expected: "203.0.113.9"},
+		{name: "trusted without header", trust: true, expected: "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trustForwardedFor = tt.trust
+			defer func() { trustForwardedFor = false }()
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := clientIP(req); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"container/list"
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultResponseCacheSize is how many encoded responses are cached by default
+	defaultResponseCacheSize = 1000
+	// responseCacheTTL bounds how long a response is reused. The key already
+	// changes with everything a response depends on, so this only caps the
+	// staleness should anything be missed.
+	responseCacheTTL = time.Minute
+)
+
+// responseCacheStats exposes the response cache counters at /debug/vars
+var responseCacheStats = expvar.NewMap("responseCache")
+
+func init() {
+	responseCacheStats.Set("size", expvar.Func(func() interface{} { return responses.len() }))
+}
+
+// responseCacheKey identifies a block_suggestion response. Besides the action
+// and query, a response depends on the options offered (which routing and
+// dependent inputs decide), the parts of the requester's scope their
+// visibility rules look at, and the selections ranking them. Requesters the
+// options do not tell apart share responses.
+type responseCacheKey struct {
+	version    uint64
+	actionID   string
+	index      *optionIndex
+	query      string
+	scope      requestScope
+	ranker     string // team and user whose past selections rank the options, if any
+	ranking    uint64 // when the ranker's selections last changed
+	popularity uint64 // when the action's selection totals last changed, if sorted by popularity
+}
+
+// newResponseCacheKey builds the cache key of a request served by a catalog entry
+func newResponseCacheKey(c *catalogIndex, matched *CatalogEntry, req SlackRequest) responseCacheKey {
+	ix := matched.indexFor(req)
+	key := responseCacheKey{
+		version:  c.version,
+		actionID: req.ActionID,
+		index:    ix,
+		query:    strings.Join(strings.Fields(foldSearchText(req.Value)), " "),
+		scope:    ix.scope.apply(scopeOf(req)),
+		ranking:  selections.rankingVersion(req.Team.ID, req.User.ID, req.ActionID),
+	}
+	if key.ranking != 0 {
+		key.ranker = selectionUserKey(req.Team.ID, req.User.ID)
+	}
+	if matched.Sort == sortPopularity {
+		key.popularity = selections.popularityVersion(req.ActionID)
+	}
+	return key
+}
+
+// responseExpiry returns when a response computed now stops being valid: after
+// responseCacheTTL, or earlier when an option's validity window opens or closes
+func responseExpiry(ix *optionIndex, now time.Time) time.Time {
+	expires := now.Add(responseCacheTTL)
+	if next, ok := nextBoundary(ix.boundaries, now); ok && next.Before(expires) {
+		return next
+	}
+	return expires
+}
+
+// cachedResponse is an encoded response held by the response cache
+type cachedResponse struct {
+	key     responseCacheKey
+	body    []byte
+	results int // number of options in body, so hits can be recorded like misses
+	expires time.Time
+}
+
+// responseCache is a bounded, least-recently-used cache of encoded
+// block_suggestion responses
+type responseCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[responseCacheKey]*list.Element
+	order    *list.List // of *cachedResponse, most recently used first
+}
+
+// responses is the process-wide response cache; nil disables caching
+var responses *responseCache
+
+// newResponseCache creates an empty response cache holding up to capacity responses
+func newResponseCache(capacity int) *responseCache {
+	return &responseCache{
+		capacity: capacity,
+		entries:  make(map[responseCacheKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns the cached response for a key, and the number of options it
+// holds, if it has not expired
+func (c *responseCache) get(key responseCacheKey, now time.Time) ([]byte, int, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok && !now.Before(elem.Value.(*cachedResponse).expires) {
+		c.remove(elem)
+		responseCacheStats.Add("expirations", 1)
+		ok = false
+	}
+	if !ok {
+		responseCacheStats.Add("misses", 1)
+		return nil, 0, false
+	}
+
+	c.order.MoveToFront(elem)
+	responseCacheStats.Add("hits", 1)
+	cached := elem.Value.(*cachedResponse)
+	return cached.body, cached.results, true
+}
+
+// put caches a response until it expires, evicting the least recently used
+// responses beyond the cache's capacity
+func (c *responseCache) put(key responseCacheKey, body []byte, results int, expires time.Time) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(&cachedResponse{key: key, body: body, results: results, expires: expires})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+		responseCacheStats.Add("evictions", 1)
+	}
+}
+
+// remove drops a cached response. The caller must hold c.mu.
+func (c *responseCache) remove(elem *list.Element) {
+	delete(c.entries, elem.Value.(*cachedResponse).key)
+	c.order.Remove(elem)
+}
+
+// clear drops every cached response. It is called whenever a catalog is
+// loaded, as responses built from the previous catalog can never be served.
+func (c *responseCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.order.Len() > 0 {
+		responseCacheStats.Add("invalidations", 1)
+	}
+	c.entries = make(map[responseCacheKey]*list.Element)
+	c.order.Init()
+}
+
+// len returns the number of cached responses
+func (c *responseCache) len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
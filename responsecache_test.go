@@ -0,0 +1,192 @@
+package main
+
+import (
+	"expvar"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// responseCacheStat returns the current value of a response cache counter
+func responseCacheStat(name string) int64 {
+	if v, ok := responseCacheStats.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// suggest sends a block_suggestion request through the HTTP handler and returns the option values
+func suggest(t *testing.T, req SlackRequest) []string {
+	t.Helper()
+	req.Type = "block_suggestion"
+	response := decodeTestResponse(t, sendTestRequest(t, "test-secret", req))
+	values := make([]string, len(response.Options))
+	for i, opt := range response.Options {
+		values[i] = opt.Value
+	}
+	return values
+}
+
+func TestResponseCache_HitsAndMisses(t *testing.T) {
+	setupTestCatalogWithMoreOptions()
+	responses = newResponseCache(10)
+	defer func() { responses = nil }()
+
+	hits, misses := responseCacheStat("hits"), responseCacheStat("misses")
+	req := SlackRequest{ActionID: "test_action", Value: "option"}
+	first := suggest(t, req)
+
+	// The same query with different case and spacing is served from the cache
+	req.Value = "  OPTION "
+	second := suggest(t, req)
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected cached response %v, got %v", first, second)
+	}
+	if got := responseCacheStat("misses") - misses; got != 1 {
+		t.Errorf("Expected 1 miss, got %d", got)
+	}
+	if got := responseCacheStat("hits") - hits; got != 1 {
+		t.Errorf("Expected 1 hit, got %d", got)
+	}
+}
+
+func TestResponseCache_InvalidatedOnReload(t *testing.T) {
+	setupTestCatalog()
+	responses = newResponseCache(10)
+	defer func() { responses = nil }()
+
+	req := SlackRequest{ActionID: "test_action"}
+	if got := suggest(t, req); !reflect.DeepEqual(got, []string{"opt1", "opt2"}) {
+		t.Fatalf("Expected [opt1 opt2], got %v", got)
+	}
+
+	setTestCatalog([]CatalogEntry{{ActionID: "test_action", Options: []Option{{Text: "Reloaded", Value: "reloaded"}}}})
+	if responses.len() != 0 {
+		t.Errorf("Expected the cache to be cleared on reload, got %d response(s)", responses.len())
+	}
+	if got := suggest(t, req); !reflect.DeepEqual(got, []string{"reloaded"}) {
+		t.Errorf("Expected [reloaded], got %v", got)
+	}
+}
+
+func TestResponseCache_SeparatesScopes(t *testing.T) {
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID: "test_action",
+			Options: []Option{
+				{Text: "Public", Value: "public"},
+				{Text: "Private", Value: "private", Visibility: &Visibility{AllowUsers: []string{"U1"}}},
+			},
+		},
+	})
+	responses = newResponseCache(10)
+	defer func() { responses = nil }()
+
+	allowed := suggest(t, SlackRequest{ActionID: "test_action", User: SlackUser{ID: "U1"}})
+	other := suggest(t, SlackRequest{ActionID: "test_action", User: SlackUser{ID: "U2"}})
+
+	if !reflect.DeepEqual(allowed, []string{"public", "private"}) {
+		t.Errorf("Expected [public private] for U1, got %v", allowed)
+	}
+	if !reflect.DeepEqual(other, []string{"public"}) {
+		t.Errorf("Expected [public] for U2, got %v", other)
+	}
+}
+
+func TestResponseCache_InvalidatedBySelections(t *testing.T) {
+	setupTestCatalog()
+	responses = newResponseCache(10)
+	selections = newSelectionStore("", 10, 10)
+	defer func() { responses, selections = nil, nil }()
+
+	req := SlackRequest{ActionID: "test_action", Team: SlackTeam{ID: "T1"}, User: SlackUser{ID: "U1"}}
+	if got := suggest(t, req); !reflect.DeepEqual(got, []string{"opt1", "opt2"}) {
+		t.Fatalf("Expected [opt1 opt2], got %v", got)
+	}
+
+	selections.record("T1", "U1", "test_action", "opt2", time.Now())
+	if got := suggest(t, req); !reflect.DeepEqual(got, []string{"opt2", "opt1"}) {
+		t.Errorf("Expected the new selection to be ranked first, got %v", got)
+	}
+}
+
+func TestResponseCache_SharedBetweenRequesters(t *testing.T) {
+	setupTestCatalog()
+	responses = newResponseCache(10)
+	selections = newSelectionStore("", 10, 10)
+	defer func() { responses, selections = nil, nil }()
+
+	hits := responseCacheStat("hits")
+	suggest(t, SlackRequest{ActionID: "test_action", Team: SlackTeam{ID: "T1"}, User: SlackUser{ID: "U1"}, Channel: SlackChannel{ID: "C1"}})
+	suggest(t, SlackRequest{ActionID: "test_action", Team: SlackTeam{ID: "T2"}, User: SlackUser{ID: "U2"}, Channel: SlackChannel{ID: "C2"}})
+
+	// Another user's selection only changes that user's ranking
+	selections.record("T1", "U3", "test_action", "opt2", time.Now())
+	suggest(t, SlackRequest{ActionID: "test_action", Team: SlackTeam{ID: "T1"}, User: SlackUser{ID: "U1"}})
+	if got := responseCacheStat("hits") - hits; got != 2 {
+		t.Errorf("Expected 2 hits, got %d", got)
+	}
+
+	got := suggest(t, SlackRequest{ActionID: "test_action", Team: SlackTeam{ID: "T1"}, User: SlackUser{ID: "U3"}})
+	if !reflect.DeepEqual(got, []string{"opt2", "opt1"}) {
+		t.Errorf("Expected U3's selection to be ranked first, got %v", got)
+	}
+}
+
+func TestResponseCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newResponseCache(2)
+	now := time.Now()
+	expires := now.Add(time.Minute)
+	a, b, c := responseCacheKey{query: "a"}, responseCacheKey{query: "b"}, responseCacheKey{query: "c"}
+
+	cache.put(a, []byte("a"), 1, expires)
+	cache.put(b, []byte("b"), 1, expires)
+	cache.get(a, now)
+	cache.put(c, []byte("c"), 1, expires)
+
+	if _, _, ok := cache.get(b, now); ok {
+		t.Error("Expected the least recently used response to be evicted")
+	}
+	if body, _, ok := cache.get(a, now); !ok || string(body) != "a" {
+		t.Errorf("Expected 'a' to stay cached, got %q", body)
+	}
+	if cache.len() != 2 {
+		t.Errorf("Expected 2 cached responses, got %d", cache.len())
+	}
+}
+
+func TestResponseCache_Expires(t *testing.T) {
+	cache := newResponseCache(2)
+	now := time.Now()
+	key := responseCacheKey{query: "a"}
+	cache.put(key, []byte("a"), 1, now.Add(time.Second))
+
+	if _, _, ok := cache.get(key, now); !ok {
+		t.Error("Expected the response to be cached before it expires")
+	}
+	if _, _, ok := cache.get(key, now.Add(time.Second)); ok {
+		t.Error("Expected the response to expire")
+	}
+	if cache.len() != 0 {
+		t.Errorf("Expected the expired response to be dropped, got %d", cache.len())
+	}
+}
+
+func TestResponseExpiry(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	soon := now.Add(10 * time.Second)
+	later := now.Add(time.Hour)
+	ix := newOptionIndex([]Option{
+		{Value: "past", ValidUntil: timePtr(now.Add(-time.Hour))},
+		{Value: "soon", ValidFrom: timePtr(soon)},
+		{Value: "later", ValidUntil: timePtr(later)},
+	})
+
+	if got := responseExpiry(ix, now); !got.Equal(soon) {
+		t.Errorf("Expected expiry at the next window boundary %v, got %v", soon, got)
+	}
+	if got := responseExpiry(ix, soon); !got.Equal(soon.Add(responseCacheTTL)) {
+		t.Errorf("Expected expiry after the TTL, got %v", got)
+	}
+}
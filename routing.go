@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// matchKind identifies how a routing pattern is matched. Higher kinds are
+// more specific and take precedence over lower ones.
+type matchKind int
+
+const (
+	matchAny matchKind = iota
+	matchRegex
+	matchGlob
+	matchPrefix
+	matchExact
+)
+
+// fieldMatcher matches a single request field (block_id or callback_id)
+// against a routing pattern from the catalog. Patterns are written as:
+//
+//	""               matches anything
+//	"deploy_block"   matches exactly
+//	"prefix:deploy_" matches values starting with "deploy_"
+//	"glob:deploy_*"  matches with '*' (any run of characters) and '?' (one character)
+//	"regex:^dep.*$"  matches the regular expression
+type fieldMatcher struct {
+	kind    matchKind
+	pattern string
+	re      *regexp.Regexp
+}
+
+// parseFieldMatcher compiles a routing pattern
+func parseFieldMatcher(spec string) (fieldMatcher, error) {
+	switch {
+	case spec == "":
+		return fieldMatcher{kind: matchAny}, nil
+	case strings.HasPrefix(spec, "prefix:"):
+		return fieldMatcher{kind: matchPrefix, pattern: strings.TrimPrefix(spec, "prefix:")}, nil
+	case strings.HasPrefix(spec, "glob:"):
+		pattern := strings.TrimPrefix(spec, "glob:")
+		quoted := regexp.QuoteMeta(pattern)
+		quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+		quoted = strings.ReplaceAll(quoted, `\?`, ".")
+		return fieldMatcher{kind: matchGlob, pattern: pattern, re: regexp.MustCompile("^" + quoted + "$")}, nil
+	case strings.HasPrefix(spec, "regex:"):
+		pattern := strings.TrimPrefix(spec, "regex:")
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fieldMatcher{}, fmt.Errorf("compiling pattern %q: %w", spec, err)
+		}
+		return fieldMatcher{kind: matchRegex, pattern: pattern, re: re}, nil
+	default:
+		return fieldMatcher{kind: matchExact, pattern: spec}, nil
+	}
+}
+
+// matches reports whether a request field value matches the pattern
+func (m fieldMatcher) matches(value string) bool {
+	switch m.kind {
+	case matchAny:
+		return true
+	case matchExact:
+		return value == m.pattern
+	case matchPrefix:
+		return strings.HasPrefix(value, m.pattern)
+	default:
+		return m.re.MatchString(value)
+	}
+}
+
+// disjointFrom reports whether two matchers of the same kind and pattern
+// length can never match the same value. Only literal patterns can be proven
+// disjoint; two glob or regex patterns may always overlap.
+func (m fieldMatcher) disjointFrom(other fieldMatcher) bool {
+	if m.kind != other.kind || (m.kind != matchExact && m.kind != matchPrefix) {
+		return false
+	}
+	return m.pattern != other.pattern
+}
+
+// route holds the compiled routing rules of a catalog entry
+type route struct {
+	block    fieldMatcher
+	callback fieldMatcher
+}
+
+// compileRoute compiles the block_id and callback_id patterns of a catalog entry
+func compileRoute(entry CatalogEntry) (route, error) {
+	block, err := parseFieldMatcher(entry.BlockID)
+	if err != nil {
+		return route{}, fmt.Errorf("blockId: %w", err)
+	}
+	callback, err := parseFieldMatcher(entry.CallbackID)
+	if err != nil {
+		return route{}, fmt.Errorf("callbackId: %w", err)
+	}
+	return route{block: block, callback: callback}, nil
+}
+
+// matches reports whether the route accepts a request
+func (r route) matches(req SlackRequest) bool {
+	return r.block.matches(req.BlockID) && r.callback.matches(requestCallbackID(req))
+}
+
+// compare orders routes by precedence: the block_id match kind, then the
+// callback_id match kind, then the length of each pattern (longer is more
+// specific). It returns a positive number when r takes precedence over other,
+// a negative number when other does, and zero when they tie.
+func (r route) compare(other route) int {
+	if c := int(r.block.kind) - int(other.block.kind); c != 0 {
+		return c
+	}
+	if c := int(r.callback.kind) - int(other.callback.kind); c != 0 {
+		return c
+	}
+	if c := len(r.block.pattern) - len(other.block.pattern); c != 0 {
+		return c
+	}
+	return len(r.callback.pattern) - len(other.callback.pattern)
+}
+
+// ambiguousWith reports whether two routes tie on precedence and could both
+// match the same request
+func (r route) ambiguousWith(other route) bool {
+	if r.compare(other) != 0 {
+		return false
+	}
+	return !r.block.disjointFrom(other.block) && !r.callback.disjointFrom(other.callback)
+}
+
+// requestCallbackID returns the callback_id of the view a request comes from,
+// falling back to the top-level callback_id used by legacy dialogs and messages
+func requestCallbackID(req SlackRequest) string {
+	if req.View != nil && req.View.CallbackID != "" {
+		return req.View.CallbackID
+	}
+	return req.CallbackID
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFieldMatcher(t *testing.T) {
+	tests := []struct {
+		spec     string
+		value    string
+		expected bool
+	}{
+		{spec: "", value: "anything", expected: true},
+		{spec: "", value: "", expected: true},
+		{spec: "deploy_block", value: "deploy_block", expected: true},
+		{spec: "deploy_block", value: "deploy_block_2", expected: false},
+		{spec: "prefix:deploy_", value: "deploy_prod", expected: true},
+		{spec: "prefix:deploy_", value: "release_prod", expected: false},
+		{spec: "glob:deploy_*_env", value: "deploy_prod_env", expected: true},
+		{spec: "glob:deploy_*_env", value: "deploy_prod_env_2", expected: false},
+		{spec: "glob:env_?", value: "env_1", expected: true},
+		{spec: "glob:a.b", value: "axb", expected: false},
+		{spec: "regex:^env_[0-9]+$", value: "env_42", expected: true},
+		{spec: "regex:^env_[0-9]+$", value: "env_x", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec+"/"+tt.value, func(t *testing.T) {
+			m, err := parseFieldMatcher(tt.spec)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := m.matches(tt.value); got != tt.expected {
+				t.Errorf("matches(%q) = %v, want %v", tt.value, got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := parseFieldMatcher("regex:("); err == nil {
+		t.Error("Expected an error for an invalid regex")
+	}
+}
+
+func TestSetCatalog_RejectsAmbiguousRoutes(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []CatalogEntry
+		ambiguous bool
+	}{
+		{
+			name:      "duplicate action IDs",
+			entries:   []CatalogEntry{{ActionID: "a"}, {ActionID: "a"}},
+			ambiguous: true,
+		},
+		{
+			name:      "different exact block IDs",
+			entries:   []CatalogEntry{{ActionID: "a", BlockID: "one"}, {ActionID: "a", BlockID: "two"}},
+			ambiguous: false,
+		},
+		{
+			name:      "same exact block ID",
+			entries:   []CatalogEntry{{ActionID: "a", BlockID: "one"}, {ActionID: "a", BlockID: "one"}},
+			ambiguous: true,
+		},
+		{
+			name:      "different precedence",
+			entries:   []CatalogEntry{{ActionID: "a", BlockID: "prefix:env"}, {ActionID: "a", BlockID: "glob:env*"}},
+			ambiguous: false,
+		},
+		{
+			name:      "equal length prefixes",
+			entries:   []CatalogEntry{{ActionID: "a", BlockID: "prefix:aa"}, {ActionID: "a", BlockID: "prefix:bb"}},
+			ambiguous: false,
+		},
+		{
+			name:      "overlapping globs",
+			entries:   []CatalogEntry{{ActionID: "a", BlockID: "glob:a*"}, {ActionID: "a", BlockID: "glob:*b"}},
+			ambiguous: true,
+		},
+		{
+			name:      "same block, different callback",
+			entries:   []CatalogEntry{{ActionID: "a", BlockID: "one", CallbackID: "x"}, {ActionID: "a", BlockID: "one", CallbackID: "y"}},
+			ambiguous: false,
+		},
+		{
+			name:      "different action IDs",
+			entries:   []CatalogEntry{{ActionID: "a"}, {ActionID: "b"}},
+			ambiguous: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := setCatalog(tt.entries)
+			if tt.ambiguous && (err == nil || !strings.Contains(err.Error(), "ambiguous")) {
+				t.Errorf("Expected an ambiguity error, got %v", err)
+			}
+			if !tt.ambiguous && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+
+	if err := setCatalog([]CatalogEntry{{ActionID: "a", CallbackID: "regex:["}}); err == nil {
+		t.Error("Expected an error for an invalid callbackId pattern")
+	}
+}
+
+func TestHandleRequest_RoutesOnBlockAndCallbackID(t *testing.T) {
+	setTestCatalog([]CatalogEntry{
+		{ActionID: "env", Options: []Option{{Text: "Default", Value: "default"}}},
+		{ActionID: "env", CallbackID: "regex:^deploy_", Options: []Option{{Text: "Deploy Regex", Value: "deploy-regex"}}},
+		{ActionID: "env", CallbackID: "prefix:deploy_", Options: []Option{{Text: "Deploy Prefix", Value: "deploy-prefix"}}},
+		{ActionID: "env", CallbackID: "deploy_modal", Options: []Option{{Text: "Deploy Modal", Value: "deploy-modal"}}},
+		{ActionID: "env", BlockID: "glob:staging_*", Options: []Option{{Text: "Staging", Value: "staging"}}},
+	})
+	secret := "test-secret"
+
+	tests := []struct {
+		name     string
+		req      SlackRequest
+		expected string
+	}{
+		{name: "no block or view", req: SlackRequest{}, expected: "default"},
+		{name: "exact callback", req: SlackRequest{View: &SlackView{CallbackID: "deploy_modal"}}, expected: "deploy-modal"},
+		{name: "prefix beats regex", req: SlackRequest{View: &SlackView{CallbackID: "deploy_other"}}, expected: "deploy-prefix"},
+		{name: "legacy callback", req: SlackRequest{CallbackID: "deploy_legacy"}, expected: "deploy-prefix"},
+		{name: "block beats callback", req: SlackRequest{BlockID: "staging_1", View: &SlackView{CallbackID: "deploy_modal"}}, expected: "staging"},
+		{name: "unmatched block", req: SlackRequest{BlockID: "prod_1"}, expected: "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Type = "block_suggestion"
+			tt.req.ActionID = "env"
+			response := decodeTestResponse(t, sendTestRequest(t, secret, tt.req))
+
+			if len(response.Options) != 1 {
+				t.Fatalf("Expected 1 option, got %d", len(response.Options))
+			}
+			if response.Options[0].Value != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, response.Options[0].Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretCheckInterval is how often a secret file is checked for changes
+const secretCheckInterval = time.Second
+
+// secretSource returns the current value of a secret
+type secretSource func() string
+
+// staticSecret returns a source for a secret that never changes
+func staticSecret(value string) secretSource {
+	return func() string { return value }
+}
+
+// fileSecret is a secret read from a file, such as a Docker or Kubernetes
+// secret mount, and reread whenever the file changes
+type fileSecret struct {
+	path string
+
+	mu      sync.Mutex
+	value   string
+	modTime time.Time
+	checked time.Time
+	lastErr error // last read error, so it is logged once rather than on every request
+}
+
+// newFileSecret reads a secret file, failing if it is missing or empty
+func newFileSecret(path string) (*fileSecret, error) {
+	s := &fileSecret{path: path}
+	if err := s.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload rereads the file if it has been modified. The caller must hold
+// s.mu, except during construction.
+func (s *fileSecret) reload(now time.Time) error {
+	s.checked = now
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("reading secret file: %w", err)
+	}
+	if s.value != "" && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("reading secret file: %w", err)
+	}
+	// Secret files often end with a newline, which is never part of the secret
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return fmt.Errorf("secret file %s is empty", s.path)
+	}
+
+	if s.value != "" && value != s.value {
+		log.Printf("Reloaded secret from %s", s.path)
+	}
+	s.value = value
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// current returns the secret, rereading the file if it has changed since it
+// was last checked. If the file cannot be read, the previous value is kept.
+func (s *fileSecret) current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.checked) < secretCheckInterval {
+		return s.value
+	}
+
+	err := s.reload(now)
+	if err != nil && (s.lastErr == nil || s.lastErr.Error() != err.Error()) {
+		log.Printf("Error reloading secret, keeping the current one: %v", err)
+	}
+	s.lastErr = err
+	return s.value
+}
+
+// newSecretSource returns a source for a secret given either directly or as
+// the path of a file holding it. It returns nil if neither is set.
+func newSecretSource(value, path string) (secretSource, error) {
+	if path == "" {
+		if value == "" {
+			return nil, nil
+		}
+		return staticSecret(value), nil
+	}
+
+	secret, err := newFileSecret(path)
+	if err != nil {
+		return nil, err
+	}
+	return secret.current, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSecretFile writes a secret file with the given modification time and returns its path
+func writeSecretFile(t *testing.T, path, content string, modTime time.Time) string {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set the modification time: %v", err)
+	}
+	return path
+}
+
+func TestFileSecret_ReloadsOnChange(t *testing.T) {
+	path := writeSecretFile(t, filepath.Join(t.TempDir(), "secret"), "first\n", time.Now().Add(-time.Minute))
+
+	secret, err := newFileSecret(path)
+	if err != nil {
+		t.Fatalf("Failed to read secret: %v", err)
+	}
+	if got := secret.current(); got != "first" {
+		t.Fatalf("Expected 'first', got %q", got)
+	}
+
+	writeSecretFile(t, path, "second\n", time.Now())
+	secret.checked = time.Time{}
+	if got := secret.current(); got != "second" {
+		t.Errorf("Expected the rotated secret 'second', got %q", got)
+	}
+
+	// A missing or empty file keeps the current secret
+	writeSecretFile(t, path, "", time.Now().Add(time.Minute))
+	secret.checked = time.Time{}
+	if got := secret.current(); got != "second" {
+		t.Errorf("Expected the current secret to be kept, got %q", got)
+	}
+}
+
+func TestFileSecret_ChecksAtMostEverySecretCheckInterval(t *testing.T) {
+	path := writeSecretFile(t, filepath.Join(t.TempDir(), "secret"), "first", time.Now().Add(-time.Minute))
+	secret, err := newFileSecret(path)
+	if err != nil {
+		t.Fatalf("Failed to read secret: %v", err)
+	}
+
+	writeSecretFile(t, path, "second", time.Now())
+	if got := secret.current(); got != "first" {
+		t.Errorf("Expected the file not to be checked again yet, got %q", got)
+	}
+}
+
+func TestNewSecretSource(t *testing.T) {
+	dir := t.TempDir()
+	path := writeSecretFile(t, filepath.Join(dir, "secret"), "from-file", time.Now())
+
+	tests := []struct {
+		name     string
+		value    string
+		path     string
+		expected string
+		wantNil  bool
+		wantErr  bool
+	}{
+		{name: "unset", wantNil: true},
+		{name: "value", value: "direct", expected: "direct"},
+		{name: "file", path: path, expected: "from-file"},
+		{name: "missing file", path: filepath.Join(dir, "missing"), wantErr: true},
+		{name: "empty file", path: writeSecretFile(t, filepath.Join(dir, "empty"), " \n", time.Now()), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := newSecretSource(tt.value, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (source == nil) != tt.wantNil {
+				t.Fatalf("Expected nil source %v", tt.wantNil)
+			}
+			if source != nil && source() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, source())
+			}
+		})
+	}
+}
+
+func TestHandleRequest_RotatedSigningSecret(t *testing.T) {
+	setupTestCatalog()
+	path := writeSecretFile(t, filepath.Join(t.TempDir(), "signing-secret"), "old-secret", time.Now().Add(-time.Minute))
+	secret, err := newFileSecret(path)
+	if err != nil {
+		t.Fatalf("Failed to read secret: %v", err)
+	}
+	handler := handleRequest(defaultCatalog, secret.current)
+	req := SlackRequest{Type: "block_suggestion", ActionID: "test_action"}
+
+	if rr := sendSignedRequest(t, handler, "old-secret", req); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 with the old secret, got %d", rr.Code)
+	}
+
+	writeSecretFile(t, path, "new-secret", time.Now())
+	secret.checked = time.Time{}
+
+	if rr := sendSignedRequest(t, handler, "new-secret", req); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the rotated secret, got %d", rr.Code)
+	}
+	if rr := sendSignedRequest(t, handler, "old-secret", req); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with the old secret, got %d", rr.Code)
+	}
+}
+
+func TestLoadConfig_SecretFiles(t *testing.T) {
+	env := map[string]string{"SLACK_SIGNING_SECRET_FILE": "/run/secrets/signing-secret"}
+	config, err := loadConfig(nil, fakeEnv(env))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.SlackSigningSecretFile != "/run/secrets/signing-secret" {
+		t.Errorf("Expected the secret file path, got %q", config.SlackSigningSecretFile)
+	}
+
+	env["SLACK_SIGNING_SECRET"] = "secret"
+	_, err = loadConfig(nil, fakeEnv(env))
+	if err == nil || !strings.Contains(err.Error(), "only one of SLACK_SIGNING_SECRET and SLACK_SIGNING_SECRET_FILE") {
+		t.Errorf("Expected an error for a secret set twice, got %v", err)
+	}
+}
+
+func TestSettings_SecretsHaveFileVariant(t *testing.T) {
+	names := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		names[s.env] = true
+	}
+	for _, s := range settings {
+		if s.secret && !names[s.env+"_FILE"] {
+			t.Errorf("Expected secret setting %s to have a %s_FILE setting", s.env, s.env)
+		}
+	}
+}
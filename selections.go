@@ -0,0 +1,403 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxSelectionUsers bounds how many users the selection store remembers
+	defaultMaxSelectionUsers = 10000
+	// defaultMaxSelectionsPerUser bounds how many distinct selections are kept per user
+	defaultMaxSelectionsPerUser = 100
+	// selectionHalfLife is how long it takes for a selection's weight to halve
+	selectionHalfLife = 7 * 24 * time.Hour
+	// selectionsSaveInterval is how often changed selections are persisted
+	selectionsSaveInterval = 10 * time.Second
+)
+
+// selectionRecord tracks how often and how recently a user picked an option
+type selectionRecord struct {
+	ActionID string    `json:"actionId"`
+	Value    string    `json:"value"`
+	Count    int       `json:"count"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// score weights the selection count by how recently the option was last picked
+func (rec selectionRecord) score(now time.Time) float64 {
+	age := now.Sub(rec.LastUsed)
+	if age < 0 {
+		age = 0
+	}
+	return float64(rec.Count) * math.Exp2(-float64(age)/float64(selectionHalfLife))
+}
+
+// userSelections holds the selections of a single user, most recent first
+type userSelections struct {
+	TeamID     string            `json:"teamId"`
+	UserID     string            `json:"userId"`
+	Selections []selectionRecord `json:"selections"`
+	changed    uint64            // the store's change count when these selections last changed
+}
+
+// selectionStore is a bounded, least-recently-used store of per-user selections,
+// keyed by team and user ID, with optional on-disk persistence
+type selectionStore struct {
+	mu            sync.Mutex
+	saveMu        sync.Mutex // serializes saves, so an older snapshot never replaces a newer one
+	path          string
+	maxUsers      int
+	maxPerUser    int
+	users         map[string]*list.Element
+	order         *list.List                // of *userSelections, most recently active first
+	totals        map[string]map[string]int // selection counts of all remembered users, by action and value
+	changes       uint64                    // incremented whenever the stored selections change
+	saved         uint64                    // value of changes when the selections were last persisted
+	totalsChanged map[string]uint64         // the change count when each action's totals last changed
+}
+
+// selections is the process-wide selection store; nil disables ranking by past selections
+var selections *selectionStore
+
+// newSelectionStore creates an empty selection store. If path is non-empty the
+// store is persisted to that file.
+func newSelectionStore(path string, maxUsers, maxPerUser int) *selectionStore {
+	return &selectionStore{
+		path:          path,
+		maxUsers:      maxUsers,
+		maxPerUser:    maxPerUser,
+		users:         make(map[string]*list.Element),
+		order:         list.New(),
+		totals:        make(map[string]map[string]int),
+		totalsChanged: make(map[string]uint64),
+	}
+}
+
+// selectionUserKey builds the key identifying a user within a team
+func selectionUserKey(teamID, userID string) string {
+	return teamID + "/" + userID
+}
+
+// record notes that a user selected an option at the given time
+func (s *selectionStore) record(teamID, userID, actionID, value string, at time.Time) {
+	if s == nil || userID == "" || actionID == "" || value == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.changes++
+	user := s.touchUser(teamID, userID)
+	user.changed = s.changes
+
+	rec := selectionRecord{ActionID: actionID, Value: value, Count: 1, LastUsed: at}
+	for i, existing := range user.Selections {
+		if existing.ActionID == actionID && existing.Value == value {
+			rec.Count = existing.Count + 1
+			user.Selections = append(user.Selections[:i], user.Selections[i+1:]...)
+			break
+		}
+	}
+	user.Selections = append([]selectionRecord{rec}, user.Selections...)
+	s.addTotal(actionID, value, 1)
+	if len(user.Selections) > s.maxPerUser {
+		for _, dropped := range user.Selections[s.maxPerUser:] {
+			s.addTotal(dropped.ActionID, dropped.Value, -dropped.Count)
+		}
+		user.Selections = user.Selections[:s.maxPerUser]
+	}
+}
+
+// addTotal adjusts the aggregate selection count of an option. The caller must hold s.mu.
+func (s *selectionStore) addTotal(actionID, value string, delta int) {
+	s.totalsChanged[actionID] = s.changes
+	counts := s.totals[actionID]
+	if counts == nil {
+		counts = make(map[string]int)
+		s.totals[actionID] = counts
+	}
+	counts[value] += delta
+	if counts[value] <= 0 {
+		delete(counts, value)
+	}
+	if len(counts) == 0 {
+		delete(s.totals, actionID)
+	}
+}
+
+// touchUser returns the selections of a user, creating them if needed and
+// marking the user as most recently active. The caller must hold s.mu.
+func (s *selectionStore) touchUser(teamID, userID string) *userSelections {
+	key := selectionUserKey(teamID, userID)
+	if elem, ok := s.users[key]; ok {
+		s.order.MoveToFront(elem)
+		return elem.Value.(*userSelections)
+	}
+
+	user := &userSelections{TeamID: teamID, UserID: userID}
+	s.users[key] = s.order.PushFront(user)
+	for s.order.Len() > s.maxUsers {
+		oldest := s.order.Back()
+		evicted := s.order.Remove(oldest).(*userSelections)
+		delete(s.users, selectionUserKey(evicted.TeamID, evicted.UserID))
+		for _, rec := range evicted.Selections {
+			s.addTotal(rec.ActionID, rec.Value, -rec.Count)
+		}
+	}
+	return user
+}
+
+// scores returns the ranking score of each option value a user has selected for an action
+func (s *selectionStore) scores(teamID, userID, actionID string, now time.Time) map[string]float64 {
+	if s == nil || userID == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.users[selectionUserKey(teamID, userID)]
+	if !ok {
+		return nil
+	}
+
+	scores := make(map[string]float64)
+	for _, rec := range elem.Value.(*userSelections).Selections {
+		if rec.ActionID == actionID {
+			scores[rec.Value] = rec.score(now)
+		}
+	}
+	return scores
+}
+
+// popularity returns how often each option value of an action has been
+// selected, summed over all remembered users
+func (s *selectionStore) popularity(actionID string) map[string]int {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int, len(s.totals[actionID]))
+	for value, count := range s.totals[actionID] {
+		counts[value] = count
+	}
+	return counts
+}
+
+// rankingVersion returns the change count when a user's selections last
+// changed, so options ranked by them can be cached until then. It is 0 if the
+// user has selected no option of the action, as nothing is then ranked.
+func (s *selectionStore) rankingVersion(teamID, userID, actionID string) uint64 {
+	if s == nil || userID == "" {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.users[selectionUserKey(teamID, userID)]
+	if !ok {
+		return 0
+	}
+	user := elem.Value.(*userSelections)
+	for _, rec := range user.Selections {
+		if rec.ActionID == actionID {
+			return user.changed
+		}
+	}
+	return 0
+}
+
+// popularityVersion returns the change count when the selection totals of an
+// action last changed, so options sorted by popularity can be cached until then
+func (s *selectionStore) popularityVersion(actionID string) uint64 {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.totalsChanged[actionID]
+}
+
+// load reads previously persisted selections from disk. A missing file is not an error.
+func (s *selectionStore) load() error {
+	if s == nil || s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading selections file: %w", err)
+	}
+
+	var users []userSelections
+	if err := json.Unmarshal(data, &users); err != nil {
+		return fmt.Errorf("parsing selections JSON: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.changes++
+	s.saved = s.changes
+	s.users = make(map[string]*list.Element)
+	s.order = list.New()
+	s.totals = make(map[string]map[string]int)
+	s.totalsChanged = make(map[string]uint64)
+	for i := range users {
+		if s.order.Len() >= s.maxUsers {
+			break
+		}
+		user := users[i]
+		if len(user.Selections) > s.maxPerUser {
+			user.Selections = user.Selections[:s.maxPerUser]
+		}
+		user.changed = s.changes
+		s.users[selectionUserKey(user.TeamID, user.UserID)] = s.order.PushBack(&user)
+		for _, rec := range user.Selections {
+			s.addTotal(rec.ActionID, rec.Value, rec.Count)
+		}
+	}
+	return nil
+}
+
+// save persists the store to disk if it changed since it was last saved,
+// replacing the previous file atomically
+func (s *selectionStore) save() error {
+	if s == nil || s.path == "" {
+		return nil
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	if s.changes == s.saved {
+		s.mu.Unlock()
+		return nil
+	}
+	changes := s.changes
+	users := make([]*userSelections, 0, s.order.Len())
+	for elem := s.order.Front(); elem != nil; elem = elem.Next() {
+		users = append(users, elem.Value.(*userSelections))
+	}
+	data, err := json.Marshal(users)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding selections: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path, ".selections-*.json", data); err != nil {
+		return fmt.Errorf("saving selections: %w", err)
+	}
+
+	s.mu.Lock()
+	s.saved = changes
+	s.mu.Unlock()
+	return nil
+}
+
+// saveEvery persists the selections at every interval when they have
+// changed, keeping file writes off the interaction path
+func (s *selectionStore) saveEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.save(); err != nil {
+			log.Printf("Error saving selections: %v", err)
+		}
+	}
+}
+
+// selectedValues returns the external select values chosen in a block_actions
+// or view_submission payload, keyed by action_id
+func selectedValues(req SlackRequest) map[string][]string {
+	values := make(map[string][]string)
+	add := func(actionType, actionID string, selected *SlackSelectedOption, multi []SlackSelectedOption) {
+		if actionType != "external_select" && actionType != "multi_external_select" {
+			return
+		}
+		if selected != nil {
+			values[actionID] = append(values[actionID], selected.Value)
+		}
+		for _, opt := range multi {
+			values[actionID] = append(values[actionID], opt.Value)
+		}
+	}
+
+	switch req.Type {
+	case "block_actions":
+		for _, action := range req.Actions {
+			add(action.Type, action.ActionID, action.SelectedOption, action.SelectedOptions)
+		}
+	case "view_submission":
+		if req.View == nil {
+			break
+		}
+		for _, block := range req.View.State.Values {
+			for actionID, state := range block {
+				add(state.Type, actionID, state.SelectedOption, state.SelectedOptions)
+			}
+		}
+	}
+	return values
+}
+
+// recordSelections stores every option selected in an interaction payload,
+// and counts those of catalog entries in the analytics. It is registered as
+// the handler for block_actions and view_submission payloads, which Slack
+// only needs acknowledged with an empty 200.
+func recordSelections(_ context.Context, c *catalogIndex, req SlackRequest) (interface{}, error) {
+	if selections == nil && analytics == nil {
+		return nil, nil
+	}
+
+	values := selectedValues(req)
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	for actionID, vals := range values {
+		_, inCatalog := c.byAction[actionID]
+		for _, value := range vals {
+			selections.record(req.Team.ID, req.User.ID, actionID, value, now)
+			if inCatalog {
+				analytics.recordSelection(actionID, value)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// rankBySelections moves options the user has picked before to the front,
+// highest score first, keeping the remaining options in their original order
+func rankBySelections(options []Option, scores map[string]float64) []Option {
+	if len(scores) == 0 || len(options) < 2 {
+		return options
+	}
+
+	ranked := make([]Option, len(options))
+	copy(ranked, options)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i].Value] > scores[ranked[j].Value]
+	})
+	return ranked
+}
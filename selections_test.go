@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectionStore_ScoresFavourRecentAndFrequent(t *testing.T) {
+	store := newSelectionStore("", 10, 10)
+	now := time.Now()
+
+	store.record("T1", "U1", "repos", "old", now.Add(-30*24*time.Hour))
+	store.record("T1", "U1", "repos", "frequent", now)
+	store.record("T1", "U1", "repos", "frequent", now)
+	store.record("T1", "U1", "repos", "recent", now)
+	store.record("T1", "U1", "other", "elsewhere", now)
+
+	scores := store.scores("T1", "U1", "repos", now)
+	if len(scores) != 3 {
+		t.Fatalf("Expected 3 scores, got %d: %v", len(scores), scores)
+	}
+	if !(scores["frequent"] > scores["recent"] && scores["recent"] > scores["old"]) {
+		t.Errorf("Expected frequent > recent > old, got %v", scores)
+	}
+
+	// Selections are scoped to team and user
+	if scores := store.scores("T2", "U1", "repos", now); len(scores) != 0 {
+		t.Errorf("Expected no scores for another team, got %v", scores)
+	}
+}
+
+func TestSelectionStore_Bounds(t *testing.T) {
+	store := newSelectionStore("", 2, 2)
+	now := time.Now()
+
+	store.record("T1", "U1", "repos", "a", now)
+	store.record("T1", "U1", "repos", "b", now)
+	store.record("T1", "U1", "repos", "c", now)
+
+	// Only the most recent selections are kept per user
+	scores := store.scores("T1", "U1", "repos", now)
+	if _, ok := scores["a"]; ok || len(scores) != 2 {
+		t.Errorf("Expected only 'b' and 'c' to be kept, got %v", scores)
+	}
+
+	// The least recently active user is evicted
+	store.record("T1", "U2", "repos", "a", now)
+	store.record("T1", "U1", "repos", "a", now)
+	store.record("T1", "U3", "repos", "a", now)
+	if scores := store.scores("T1", "U2", "repos", now); len(scores) != 0 {
+		t.Errorf("Expected U2 to be evicted, got %v", scores)
+	}
+	if scores := store.scores("T1", "U1", "repos", now); len(scores) == 0 {
+		t.Error("Expected U1 to be kept")
+	}
+}
+
+func TestSelectionStore_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "selections.json")
+	now := time.Now()
+
+	store := newSelectionStore(path, 10, 10)
+	store.record("T1", "U1", "repos", "InnerGate", now)
+	store.record("T1", "U1", "repos", "InnerGate", now)
+	if err := store.save(); err != nil {
+		t.Fatalf("Failed to save selections: %v", err)
+	}
+
+	loaded := newSelectionStore(path, 10, 10)
+	if err := loaded.load(); err != nil {
+		t.Fatalf("Failed to load selections: %v", err)
+	}
+	if got, want := loaded.scores("T1", "U1", "repos", now), store.scores("T1", "U1", "repos", now); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected loaded scores %v, got %v", want, got)
+	}
+
+	// Unchanged selections are not written again
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Failed to remove selections file: %v", err)
+	}
+	if err := store.save(); err != nil {
+		t.Fatalf("Failed to save selections: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected unchanged selections not to be saved, got %v", err)
+	}
+
+	// A missing file is not an error
+	missing := newSelectionStore(filepath.Join(t.TempDir(), "missing.json"), 10, 10)
+	if err := missing.load(); err != nil {
+		t.Errorf("Expected no error for missing file, got %v", err)
+	}
+}
+
+func TestSelectedValues(t *testing.T) {
+	blockActions := SlackRequest{
+		Type: "block_actions",
+		Actions: []SlackAction{
+			{Type: "external_select", ActionID: "repos", SelectedOption: &SlackSelectedOption{Value: "InnerGate"}},
+			{Type: "multi_external_select", ActionID: "teams", SelectedOptions: []SlackSelectedOption{{Value: "a"}, {Value: "b"}}},
+			{Type: "button", ActionID: "submit"},
+		},
+	}
+	expected := map[string][]string{"repos": {"InnerGate"}, "teams": {"a", "b"}}
+	if got := selectedValues(blockActions); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	viewSubmission := SlackRequest{
+		Type: "view_submission",
+		View: &SlackView{State: SlackViewState{Values: map[string]map[string]SlackStateValue{
+			"repo_block":  {"repos": {Type: "external_select", SelectedOption: &SlackSelectedOption{Value: "Poppit"}}},
+			"title_block": {"title": {Type: "plain_text_input", Value: "Hello"}},
+		}}},
+	}
+	expected = map[string][]string{"repos": {"Poppit"}}
+	if got := selectedValues(viewSubmission); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestHandleRequest_BoostsSelectedOptions(t *testing.T) {
+	setupTestCatalogWithMoreOptions()
+	selections = newSelectionStore("", 10, 10)
+	defer func() { selections = nil }()
+	secret := "test-secret"
+
+	// Record a selection of "Poppit" by U1
+	rr := sendTestRequest(t, secret, SlackRequest{
+		Type: "block_actions",
+		Team: SlackTeam{ID: "T1"},
+		User: SlackUser{ID: "U1"},
+		Actions: []SlackAction{
+			{Type: "external_select", ActionID: "test_action", SelectedOption: &SlackSelectedOption{Value: "Poppit"}},
+		},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	// U1 now sees "Poppit" first
+	response := decodeTestResponse(t, sendTestRequest(t, secret, SlackRequest{
+		Type:     "block_suggestion",
+		ActionID: "test_action",
+		Team:     SlackTeam{ID: "T1"},
+		User:     SlackUser{ID: "U1"},
+	}))
+	if len(response.Options) != 5 || response.Options[0].Value != "Poppit" {
+		t.Errorf("Expected 'Poppit' to be ranked first, got %+v", response.Options)
+	}
+
+	// Other users keep the file order
+	response = decodeTestResponse(t, sendTestRequest(t, secret, SlackRequest{
+		Type:     "block_suggestion",
+		ActionID: "test_action",
+		Team:     SlackTeam{ID: "T1"},
+		User:     SlackUser{ID: "U2"},
+	}))
+	if len(response.Options) != 5 || response.Options[0].Value != "InnerGate" {
+		t.Errorf("Expected file order for other users, got %+v", response.Options)
+	}
+}
+
+func TestSelectionStore_Popularity(t *testing.T) {
+	store := newSelectionStore("", 2, 2)
+	now := time.Now()
+
+	store.record("T1", "U1", "repos", "a", now)
+	store.record("T1", "U1", "repos", "a", now)
+	store.record("T1", "U2", "repos", "a", now)
+	store.record("T1", "U2", "repos", "b", now)
+
+	expected := map[string]int{"a": 3, "b": 1}
+	if got := store.popularity("repos"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Evicting a user removes their selections from the totals
+	store.record("T1", "U3", "repos", "c", now)
+	expected = map[string]int{"a": 1, "b": 1, "c": 1}
+	if got := store.popularity("repos"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v after eviction, got %v", expected, got)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxSlashCommandResults limits how many options are listed in a slash command reply
+	maxSlashCommandResults = 25
+	// maxSectionTextLength is the maximum length of a Block Kit section's text
+	maxSectionTextLength = 3000
+)
+
+// SlashCommand represents a Slack slash command invocation
+type SlashCommand struct {
+	Command     string `json:"command"`
+	Text        string `json:"text"`
+	UserID      string `json:"user_id"`
+	TeamID      string `json:"team_id"`
+	ChannelID   string `json:"channel_id"`
+	ResponseURL string `json:"response_url"`
+}
+
+// SlashCommandResponse represents the message sent in reply to a slash command
+type SlashCommandResponse struct {
+	ResponseType string       `json:"response_type"`
+	Text         string       `json:"text"`
+	Blocks       []SlackBlock `json:"blocks,omitempty"`
+}
+
+// SlackBlock represents a Block Kit layout block
+type SlackBlock struct {
+	Type     string      `json:"type"`
+	Text     *SlackText  `json:"text,omitempty"`
+	Elements []SlackText `json:"elements,omitempty"`
+}
+
+// parseSlashCommand extracts a slash command from its form fields
+func parseSlashCommand(values url.Values) SlashCommand {
+	return SlashCommand{
+		Command:     values.Get("command"),
+		Text:        values.Get("text"),
+		UserID:      values.Get("user_id"),
+		TeamID:      values.Get("team_id"),
+		ChannelID:   values.Get("channel_id"),
+		ResponseURL: values.Get("response_url"),
+	}
+}
+
+// handleSlashCommand handles "/catalog <actionId> [query]" slash commands,
+// replying with an ephemeral list of matching options
+func handleSlashCommand(catalog *catalogStore, signingSecret secretSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, ok := readVerifiedBody(w, r, signingSecret)
+		if !ok {
+			return
+		}
+
+		contentType := r.Header.Get("Content-Type")
+		if parseMediaType(contentType) != "application/x-www-form-urlencoded" {
+			log.Printf("Unsupported content type: %s", contentType)
+			http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		_, span := startSpan(r.Context(), "slack.parse")
+		values, err := url.ParseQuery(string(body))
+		endSpan(span, err)
+		if err != nil {
+			log.Printf("Error parsing form data: %v", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		cmd := parseSlashCommand(values)
+		if !allowTeam(w, cmd.TeamID) {
+			return
+		}
+		log.Printf("Received slash command %s from user %s", cmd.Command, cmd.UserID)
+
+		response := runSlashCommand(r.Context(), catalog.current(), cmd)
+
+		_, span = startSpan(r.Context(), "slack.encode")
+		defer span.End()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Printf("Error encoding response: %v", err)
+		}
+	}
+}
+
+// runSlashCommand looks up the options matching a slash command and formats them as an ephemeral reply
+func runSlashCommand(ctx context.Context, c *catalogIndex, cmd SlashCommand) SlashCommandResponse {
+	actionID, query, _ := strings.Cut(strings.TrimSpace(cmd.Text), " ")
+	scope := requestScope{TeamID: cmd.TeamID, UserID: cmd.UserID, ChannelID: cmd.ChannelID}
+	if actionID == "" {
+		return slashCommandUsage(c, cmd.Command, scope)
+	}
+
+	slackReq := SlackRequest{
+		Type:     "block_suggestion",
+		ActionID: actionID,
+		Value:    strings.TrimSpace(query),
+		Team:     SlackTeam{ID: cmd.TeamID},
+		User:     SlackUser{ID: cmd.UserID},
+		Channel:  SlackChannel{ID: cmd.ChannelID},
+	}
+	matched := c.traceLookup(ctx, func() *CatalogEntry { return c.commandEntry(actionID, scope) })
+	if matched == nil {
+		text := fmt.Sprintf("No catalog entry found for `%s`.", escapeMrkdwn(actionID))
+		return ephemeralResponse(text, sectionBlock(text))
+	}
+
+	options := filterOptions(ctx, matched, slackReq)
+	analytics.recordSearch(actionID, slackReq.Value, len(options))
+	return formatOptionsResponse(actionID, slackReq.Value, options)
+}
+
+// commandEntry returns the entry a slash command searches for an action ID.
+// A command carries no block_id or callback_id, so blockId and callbackId
+// rules are ignored and the visible entry taking precedence wins.
+func (c *catalogIndex) commandEntry(actionID string, scope requestScope) *CatalogEntry {
+	var best *CatalogEntry
+	for _, entry := range c.byAction[actionID] {
+		if !entry.Visibility.allows(scope) {
+			continue
+		}
+		if best == nil || entry.route.compare(best.route) > 0 {
+			best = entry
+		}
+	}
+	return best
+}
+
+// slashCommandUsage lists the action IDs a command can search for the requester
+func slashCommandUsage(c *catalogIndex, command string, scope requestScope) SlashCommandResponse {
+	if command == "" {
+		command = "/catalog"
+	}
+
+	var actionIDs []string
+	for actionID := range c.byAction {
+		if c.commandEntry(actionID, scope) != nil {
+			actionIDs = append(actionIDs, "`"+escapeMrkdwn(actionID)+"`")
+		}
+	}
+	sort.Strings(actionIDs)
+
+	text := fmt.Sprintf("Usage: `%s <actionId> [query]`", escapeMrkdwn(command))
+	blocks := []SlackBlock{sectionBlock(text)}
+	if len(actionIDs) > 0 {
+		blocks = append(blocks, contextBlock("Available action IDs: "+strings.Join(actionIDs, ", ")))
+	}
+	return ephemeralResponse(text, blocks...)
+}
+
+// formatOptionsResponse formats matching options as a Block Kit list
+func formatOptionsResponse(actionID, query string, options []Option) SlashCommandResponse {
+	summary := fmt.Sprintf("%d option(s) for `%s`", len(options), escapeMrkdwn(actionID))
+	if query != "" {
+		summary += fmt.Sprintf(" matching `%s`", escapeMrkdwn(query))
+	}
+	if len(options) == 0 {
+		return ephemeralResponse(summary, sectionBlock(summary))
+	}
+
+	var lines []string
+	length := 0
+	for _, opt := range options {
+		if len(lines) == maxSlashCommandResults {
+			break
+		}
+
+		line := fmt.Sprintf("• *%s* `%s`", escapeMrkdwn(opt.Text), escapeMrkdwn(opt.Value))
+		if opt.Description != "" {
+			line += " - " + escapeMrkdwn(opt.Description)
+		}
+		if length+len(line)+1 > maxSectionTextLength {
+			break
+		}
+		lines = append(lines, line)
+		length += len(line) + 1
+	}
+
+	blocks := []SlackBlock{
+		sectionBlock(summary),
+		sectionBlock(strings.Join(lines, "\n")),
+	}
+	if len(lines) < len(options) {
+		blocks = append(blocks, contextBlock(fmt.Sprintf("Showing %d of %d. Refine your query to narrow the results.", len(lines), len(options))))
+	}
+	return ephemeralResponse(summary, blocks...)
+}
+
+// ephemeralResponse builds a reply only visible to the user who ran the command
+func ephemeralResponse(text string, blocks ...SlackBlock) SlashCommandResponse {
+	return SlashCommandResponse{
+		ResponseType: "ephemeral",
+		Text:         text,
+		Blocks:       blocks,
+	}
+}
+
+// sectionBlock builds a section block with mrkdwn text
+func sectionBlock(text string) SlackBlock {
+	return SlackBlock{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: text}}
+}
+
+// contextBlock builds a context block with a single mrkdwn element
+func contextBlock(text string) SlackBlock {
+	return SlackBlock{Type: "context", Elements: []SlackText{{Type: "mrkdwn", Text: text}}}
+}
+
+// escapeMrkdwn escapes the characters Slack treats as control sequences in mrkdwn text
+func escapeMrkdwn(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sendSlashCommand signs and sends a slash command to the handler and returns the recorded response
+func sendSlashCommand(t *testing.T, secret string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body := form.Encode()
+	req := httptest.NewRequest(http.MethodPost, "/commands", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", generateTestSignature(secret, timestamp, []byte(body)))
+
+	rr := httptest.NewRecorder()
+	handleSlashCommand(defaultCatalog, staticSecret(secret)).ServeHTTP(rr, req)
+	return rr
+}
+
+// decodeSlashCommandResponse decodes a SlashCommandResponse from a recorded response
+func decodeSlashCommandResponse(t *testing.T, rr *httptest.ResponseRecorder) SlashCommandResponse {
+	t.Helper()
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response SlashCommandResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return response
+}
+
+func TestParseSlashCommand(t *testing.T) {
+	values := url.Values{
+		"command":      {"/catalog"},
+		"text":         {"test_action slack"},
+		"user_id":      {"U1"},
+		"team_id":      {"T1"},
+		"channel_id":   {"C1"},
+		"response_url": {"https://hooks.slack.com/commands/1"},
+	}
+
+	expected := SlashCommand{
+		Command:     "/catalog",
+		Text:        "test_action slack",
+		UserID:      "U1",
+		TeamID:      "T1",
+		ChannelID:   "C1",
+		ResponseURL: "https://hooks.slack.com/commands/1",
+	}
+	if got := parseSlashCommand(values); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestHandleSlashCommand_ListsMatchingOptions(t *testing.T) {
+	setupTestCatalogWithMoreOptions()
+	secret := "test-secret"
+
+	rr := sendSlashCommand(t, secret, url.Values{
+		"command": {"/catalog"},
+		"text":    {"test_action slack"},
+		"user_id": {"U1"},
+	})
+	response := decodeSlashCommandResponse(t, rr)
+
+	if response.ResponseType != "ephemeral" {
+		t.Errorf("Expected ephemeral response, got '%s'", response.ResponseType)
+	}
+	if response.Text != "2 option(s) for `test_action` matching `slack`" {
+		t.Errorf("Unexpected text: %s", response.Text)
+	}
+	if len(response.Blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(response.Blocks))
+	}
+
+	list := response.Blocks[1].Text.Text
+	if !strings.Contains(list, "*OctoSlack*") || !strings.Contains(list, "*SlackLiner*") || strings.Contains(list, "Poppit") {
+		t.Errorf("Unexpected option list: %s", list)
+	}
+}
+
+func TestHandleSlashCommand_Usage(t *testing.T) {
+	setupTestCatalog()
+	secret := "test-secret"
+
+	rr := sendSlashCommand(t, secret, url.Values{"command": {"/catalog"}, "text": {""}})
+	response := decodeSlashCommandResponse(t, rr)
+
+	if !strings.HasPrefix(response.Text, "Usage: `/catalog <actionId> [query]`") {
+		t.Errorf("Unexpected text: %s", response.Text)
+	}
+	if len(response.Blocks) != 2 || !strings.Contains(response.Blocks[1].Elements[0].Text, "`test_action`") {
+		t.Errorf("Expected available action IDs to be listed, got %+v", response.Blocks)
+	}
+}
+
+func TestHandleSlashCommand_IgnoresRouting(t *testing.T) {
+	setTestCatalog([]CatalogEntry{
+		{ActionID: "env", BlockID: "deploy_block", Options: []Option{{Text: "Production", Value: "prod"}}},
+		{ActionID: "env", BlockID: "glob:deploy_*", Options: []Option{{Text: "Staging", Value: "staging"}}},
+		{ActionID: "secret", CallbackID: "admin_modal", Visibility: &Visibility{AllowTeams: []string{"T1"}}, Options: []Option{{Text: "Secret", Value: "secret"}}},
+	})
+	secret := "test-secret"
+
+	// The entry taking precedence serves the command, whatever its blockId
+	response := decodeSlashCommandResponse(t, sendSlashCommand(t, secret, url.Values{"command": {"/catalog"}, "text": {"env"}, "team_id": {"T2"}}))
+	if !strings.HasPrefix(response.Text, "1 option(s) for `env`") || !strings.Contains(response.Blocks[1].Text.Text, "`prod`") {
+		t.Errorf("Expected the deploy_block entry's options, got %+v", response)
+	}
+
+	// Only the action IDs the requester can search are listed
+	tests := []struct {
+		team     string
+		expected string
+	}{
+		{team: "T1", expected: "Available action IDs: `env`, `secret`"},
+		{team: "T2", expected: "Available action IDs: `env`"},
+	}
+	for _, tt := range tests {
+		response := decodeSlashCommandResponse(t, sendSlashCommand(t, secret, url.Values{"command": {"/catalog"}, "team_id": {tt.team}}))
+		if len(response.Blocks) != 2 || response.Blocks[1].Elements[0].Text != tt.expected {
+			t.Errorf("%s: expected %q, got %+v", tt.team, tt.expected, response.Blocks)
+		}
+	}
+}
+
+func TestHandleSlashCommand_UnknownActionID(t *testing.T) {
+	setupTestCatalog()
+	secret := "test-secret"
+
+	rr := sendSlashCommand(t, secret, url.Values{"command": {"/catalog"}, "text": {"<missing>"}})
+	response := decodeSlashCommandResponse(t, rr)
+
+	if response.Text != "No catalog entry found for `&lt;missing&gt;`." {
+		t.Errorf("Unexpected text: %s", response.Text)
+	}
+}
+
+func TestHandleSlashCommand_TruncatesResults(t *testing.T) {
+	options := make([]Option, maxSlashCommandResults+5)
+	for i := range options {
+		options[i] = Option{Text: fmt.Sprintf("Repo %d", i), Value: fmt.Sprintf("repo-%d", i)}
+	}
+	setTestCatalog([]CatalogEntry{{ActionID: "test_action", Options: options}})
+	secret := "test-secret"
+
+	rr := sendSlashCommand(t, secret, url.Values{"command": {"/catalog"}, "text": {"test_action"}})
+	response := decodeSlashCommandResponse(t, rr)
+
+	if len(response.Blocks) != 3 {
+		t.Fatalf("Expected 3 blocks, got %d", len(response.Blocks))
+	}
+	if lines := strings.Count(response.Blocks[1].Text.Text, "\n") + 1; lines != maxSlashCommandResults {
+		t.Errorf("Expected %d listed options, got %d", maxSlashCommandResults, lines)
+	}
+	expected := fmt.Sprintf("Showing %d of %d.", maxSlashCommandResults, len(options))
+	if !strings.HasPrefix(response.Blocks[2].Elements[0].Text, expected) {
+		t.Errorf("Unexpected context: %s", response.Blocks[2].Elements[0].Text)
+	}
+}
+
+func TestHandleSlashCommand_InvalidSignature(t *testing.T) {
+	setupTestCatalog()
+
+	body := url.Values{"command": {"/catalog"}, "text": {"test_action"}}.Encode()
+	req := httptest.NewRequest(http.MethodPost, "/commands", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Sign with a different secret than the handler expects
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", generateTestSignature("wrong-secret", timestamp, []byte(body)))
+
+	rr := httptest.NewRecorder()
+	handleSlashCommand(defaultCatalog, staticSecret("test-secret")).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
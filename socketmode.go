@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// defaultSlackAPIURL is the base URL of the Slack Web API
+	defaultSlackAPIURL = "https://slack.com/api/"
+	// socketModeMaxBackoff caps the delay between reconnection attempts
+	socketModeMaxBackoff = 30 * time.Second
+)
+
+// socketModeEnvelope represents a message received over a Socket Mode connection
+type socketModeEnvelope struct {
+	Type       string          `json:"type"`
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload"`
+	Reason     string          `json:"reason"`
+}
+
+// socketModeAck acknowledges an envelope, optionally carrying a response payload
+type socketModeAck struct {
+	EnvelopeID string      `json:"envelope_id"`
+	Payload    interface{} `json:"payload,omitempty"`
+}
+
+// socketModeClient receives Slack interactions over a Socket Mode WebSocket
+// instead of the public HTTP endpoint
+type socketModeClient struct {
+	catalog    *catalogStore
+	appToken   secretSource
+	apiURL     string // base URL of the Slack Web API
+	socketURL  string // overrides the URL returned by apps.connections.open when set
+	httpClient *http.Client
+}
+
+// newSocketModeClient creates a Socket Mode client serving a catalog,
+// authenticated with an app-level token. If socketURL is non-empty it is dialled directly.
+func newSocketModeClient(catalog *catalogStore, appToken secretSource, socketURL string) *socketModeClient {
+	return &socketModeClient{
+		catalog:    catalog,
+		appToken:   appToken,
+		apiURL:     defaultSlackAPIURL,
+		socketURL:  socketURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// run connects to Slack and serves envelopes until ctx is cancelled,
+// reconnecting with exponential backoff whenever the connection drops
+func (c *socketModeClient) run(ctx context.Context) error {
+	backoff := time.Second
+	for {
+		err := c.connectAndServe(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err == nil {
+			// Slack asked us to reconnect, so do it straight away
+			backoff = time.Second
+			continue
+		}
+
+		log.Printf("Socket Mode connection failed, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, socketModeMaxBackoff)
+	}
+}
+
+// connectAndServe opens a single WebSocket connection and serves it. It
+// returns nil when Slack requests a disconnect.
+func (c *socketModeClient) connectAndServe(ctx context.Context) error {
+	wsURL, err := c.connectionURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	config, err := websocket.NewConfig(wsURL, "https://slack.com")
+	if err != nil {
+		return fmt.Errorf("parsing Socket Mode URL: %w", err)
+	}
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		return fmt.Errorf("dialing Socket Mode URL: %w", err)
+	}
+	defer conn.Close()
+
+	// Unblock the read loop when the context is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	log.Printf("Socket Mode connection established")
+	return c.serve(conn)
+}
+
+// connectionURL returns the WebSocket URL to dial, requesting a new one from
+// apps.connections.open unless it has been overridden
+func (c *socketModeClient) connectionURL(ctx context.Context) (string, error) {
+	if c.socketURL != "" {
+		return c.socketURL, nil
+	}
+
+	endpoint, err := url.JoinPath(c.apiURL, "apps.connections.open")
+	if err != nil {
+		return "", fmt.Errorf("building connections URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating connections request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.appToken())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("opening Socket Mode connection: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		URL   string `json:"url"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("parsing connections response: %w", err)
+	}
+	if !result.OK {
+		return "", fmt.Errorf("apps.connections.open failed: %s", result.Error)
+	}
+	return result.URL, nil
+}
+
+// serve reads envelopes from the connection and acknowledges each one
+func (c *socketModeClient) serve(conn *websocket.Conn) error {
+	for {
+		var envelope socketModeEnvelope
+		if err := websocket.JSON.Receive(conn, &envelope); err != nil {
+			return fmt.Errorf("reading Socket Mode message: %w", err)
+		}
+
+		switch envelope.Type {
+		case "hello":
+			continue
+		case "disconnect":
+			log.Printf("Socket Mode disconnect requested: %s", envelope.Reason)
+			return nil
+		}
+
+		if envelope.EnvelopeID == "" {
+			continue
+		}
+		ack := handleSocketModeEnvelope(c.catalog, envelope)
+		if err := websocket.JSON.Send(conn, ack); err != nil {
+			return fmt.Errorf("sending Socket Mode ack: %w", err)
+		}
+	}
+}
+
+// handleSocketModeEnvelope runs an envelope through the same handlers as the
+// HTTP endpoints and builds its acknowledgement
+func handleSocketModeEnvelope(catalog *catalogStore, envelope socketModeEnvelope) socketModeAck {
+	ack := socketModeAck{EnvelopeID: envelope.EnvelopeID}
+	ctx, span := startSpan(context.Background(), "slack.socket_mode", attribute.String("slack.envelope_type", envelope.Type))
+	defer span.End()
+
+	switch envelope.Type {
+	case "interactive":
+		var slackReq SlackRequest
+		if err := json.Unmarshal(envelope.Payload, &slackReq); err != nil {
+			log.Printf("Error parsing Socket Mode payload JSON: %v", err)
+			return ack
+		}
+
+		span.SetAttributes(requestAttributes(slackReq)...)
+		response, err := dispatchInteraction(ctx, catalog.current(), slackReq)
+		if err != nil {
+			log.Printf("Error handling %s interaction: %v", slackReq.Type, err)
+			return ack
+		}
+		if response != nil {
+			ack.Payload = response
+		}
+	case "slash_commands":
+		var cmd SlashCommand
+		if err := json.Unmarshal(envelope.Payload, &cmd); err != nil {
+			log.Printf("Error parsing Socket Mode payload JSON: %v", err)
+			return ack
+		}
+
+		log.Printf("Received slash command %s from user %s", cmd.Command, cmd.UserID)
+		ack.Payload = runSlashCommand(ctx, catalog.current(), cmd)
+	default:
+		log.Printf("Acknowledging unsupported Socket Mode envelope type: %s", envelope.Type)
+	}
+	return ack
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// receivedAck holds an acknowledgement received by the fake Socket Mode server
+type receivedAck struct {
+	EnvelopeID string        `json:"envelope_id"`
+	Payload    SlackResponse `json:"payload"`
+}
+
+// newFakeSocketModeServer starts a WebSocket server that sends the given
+// envelopes in order and reports each acknowledgement it receives
+func newFakeSocketModeServer(t *testing.T, envelopes []interface{}, acks chan<- receivedAck) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		for _, envelope := range envelopes {
+			if err := websocket.JSON.Send(conn, envelope); err != nil {
+				t.Errorf("Failed to send envelope: %v", err)
+				return
+			}
+
+			// Only envelopes with an ID are acknowledged
+			if m, ok := envelope.(map[string]interface{}); !ok || m["envelope_id"] == nil {
+				continue
+			}
+			var ack receivedAck
+			if err := websocket.JSON.Receive(conn, &ack); err != nil {
+				t.Errorf("Failed to receive ack: %v", err)
+				return
+			}
+			acks <- ack
+		}
+
+		// Keep the connection open until the client goes away
+		var discard json.RawMessage
+		websocket.JSON.Receive(conn, &discard)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSocketModeClient_AcksBlockSuggestionWithOptions(t *testing.T) {
+	setupTestCatalogWithMoreOptions()
+
+	acks := make(chan receivedAck, 1)
+	server := newFakeSocketModeServer(t, []interface{}{
+		map[string]interface{}{"type": "hello"},
+		map[string]interface{}{
+			"type":        "interactive",
+			"envelope_id": "env-1",
+			"payload": map[string]interface{}{
+				"type":      "block_suggestion",
+				"action_id": "test_action",
+				"value":     "slack",
+			},
+			"accepts_response_payload": true,
+		},
+	}, acks)
+
+	client := newSocketModeClient(defaultCatalog, staticSecret("xapp-test"), "ws"+strings.TrimPrefix(server.URL, "http"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- client.run(ctx) }()
+
+	select {
+	case ack := <-acks:
+		if ack.EnvelopeID != "env-1" {
+			t.Errorf("Expected envelope_id 'env-1', got '%s'", ack.EnvelopeID)
+		}
+		if len(ack.Payload.Options) != 2 {
+			t.Fatalf("Expected 2 options, got %d", len(ack.Payload.Options))
+		}
+		if ack.Payload.Options[0].Text.Text != "OctoSlack" || ack.Payload.Options[1].Text.Text != "SlackLiner" {
+			t.Errorf("Unexpected options: %+v", ack.Payload.Options)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for ack")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for client to stop")
+	}
+}
+
+func TestHandleSocketModeEnvelope_UnsupportedTypes(t *testing.T) {
+	setupTestCatalog()
+
+	// Non-interactive envelopes are acknowledged without a payload
+	ack := handleSocketModeEnvelope(defaultCatalog, socketModeEnvelope{Type: "events_api", EnvelopeID: "env-1"})
+	if ack.EnvelopeID != "env-1" || ack.Payload != nil {
+		t.Errorf("Unexpected ack: %+v", ack)
+	}
+
+	// Interactions that don't produce a response are acknowledged without a payload
+	ack = handleSocketModeEnvelope(defaultCatalog, socketModeEnvelope{
+		Type:       "interactive",
+		EnvelopeID: "env-2",
+		Payload:    json.RawMessage(`{"type":"view_closed"}`),
+	})
+	if ack.EnvelopeID != "env-2" || ack.Payload != nil {
+		t.Errorf("Unexpected ack: %+v", ack)
+	}
+}
+
+func TestSocketModeClient_ConnectionURL(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apps.connections.open" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer xapp-test" {
+			t.Errorf("Unexpected Authorization header: %s", auth)
+		}
+		w.Write([]byte(`{"ok":true,"url":"wss://example.invalid/link"}`))
+	}))
+	defer api.Close()
+
+	client := newSocketModeClient(defaultCatalog, staticSecret("xapp-test"), "")
+	client.apiURL = api.URL
+	got, err := client.connectionURL(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "wss://example.invalid/link" {
+		t.Errorf("Expected 'wss://example.invalid/link', got '%s'", got)
+	}
+
+	// An overridden URL is used without calling the API
+	client = newSocketModeClient(defaultCatalog, staticSecret("xapp-test"), "ws://localhost:1234")
+	client.apiURL = "http://127.0.0.1:0"
+	if got, err := client.connectionURL(context.Background()); err != nil || got != "ws://localhost:1234" {
+		t.Errorf("Expected override URL, got '%s' (err: %v)", got, err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSlackOptions is the maximum number of options Slack accepts in a suggestion response
+const maxSlackOptions = 100
+
+// Sort orders supported by CatalogEntry.Sort
+const (
+	sortFile       = "file"
+	sortAlpha      = "alpha"
+	sortAlphaCI    = "alpha-ci"
+	sortNatural    = "natural"
+	sortWeight     = "weight"
+	sortPopularity = "popularity"
+)
+
+// validSortOrders lists the accepted values of CatalogEntry.Sort; empty means file order
+var validSortOrders = map[string]bool{
+	"":             true,
+	sortFile:       true,
+	sortAlpha:      true,
+	sortAlphaCI:    true,
+	sortNatural:    true,
+	sortWeight:     true,
+	sortPopularity: true,
+}
+
+// sortOptions sorts options in place by the given sort order. All orders are
+// stable, so options that compare equal keep their file order. popularity
+// holds selection counts by option value and is only used by "popularity".
+func sortOptions(options []Option, order string, popularity map[string]int) {
+	var less func(a, b Option) bool
+	switch order {
+	case sortAlpha:
+		less = func(a, b Option) bool { return a.Text < b.Text }
+	case sortAlphaCI:
+		less = func(a, b Option) bool { return strings.ToLower(a.Text) < strings.ToLower(b.Text) }
+	case sortNatural:
+		less = func(a, b Option) bool { return naturalLess(strings.ToLower(a.Text), strings.ToLower(b.Text)) }
+	case sortWeight:
+		less = func(a, b Option) bool { return a.Weight > b.Weight }
+	case sortPopularity:
+		less = func(a, b Option) bool { return popularity[a.Value] > popularity[b.Value] }
+	default:
+		return
+	}
+
+	sort.SliceStable(options, func(i, j int) bool {
+		return less(options[i], options[j])
+	})
+}
+
+// naturalLess compares strings treating runs of digits as numbers, so that
+// "release-9" sorts before "release-10"
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+		if aDigits != "" && bDigits != "" {
+			if c := compareNumeric(aDigits, bDigits); c != 0 {
+				return c < 0
+			}
+			a, b = a[len(aDigits):], b[len(bDigits):]
+			continue
+		}
+
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// leadingDigits returns the run of ASCII digits at the start of s
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// compareNumeric compares two digit strings by numeric value without
+// overflowing, ignoring leading zeros
+func compareNumeric(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
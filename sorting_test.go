@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// optionValues returns the values of options in order
+func optionValues(options []Option) []string {
+	values := make([]string, len(options))
+	for i, opt := range options {
+		values[i] = opt.Value
+	}
+	return values
+}
+
+func TestSortOptions(t *testing.T) {
+	newOptions := func() []Option {
+		return []Option{
+			{Text: "release-10", Value: "r10", Weight: 1},
+			{Text: "Beta", Value: "beta", Weight: 5},
+			{Text: "alpha", Value: "alpha"},
+			{Text: "release-9", Value: "r9", Weight: 5},
+		}
+	}
+	popularity := map[string]int{"alpha": 3, "r9": 7}
+
+	tests := []struct {
+		order    string
+		expected []string
+	}{
+		{order: "", expected: []string{"r10", "beta", "alpha", "r9"}},
+		{order: sortFile, expected: []string{"r10", "beta", "alpha", "r9"}},
+		{order: sortAlpha, expected: []string{"beta", "alpha", "r10", "r9"}},
+		{order: sortAlphaCI, expected: []string{"alpha", "beta", "r10", "r9"}},
+		{order: sortNatural, expected: []string{"alpha", "beta", "r9", "r10"}},
+		{order: sortWeight, expected: []string{"beta", "r9", "r10", "alpha"}},
+		{order: sortPopularity, expected: []string{"r9", "alpha", "r10", "beta"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			options := newOptions()
+			sortOptions(options, tt.order, popularity)
+			if got := optionValues(options); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "v2", b: "v10", expected: true},
+		{a: "v10", b: "v2", expected: false},
+		{a: "v1.9.0", b: "v1.10.0", expected: true},
+		{a: "file007", b: "file7", expected: false},
+		{a: "file7", b: "file007", expected: false},
+		{a: "abc", b: "abd", expected: true},
+		{a: "ab", b: "abc", expected: true},
+		{a: "99999999999999999999999", b: "100000000000000000000000", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"<"+tt.b, func(t *testing.T) {
+			if got := naturalLess(tt.a, tt.b); got != tt.expected {
+				t.Errorf("naturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetCatalog_RejectsUnknownSortOrder(t *testing.T) {
+	err := setCatalog([]CatalogEntry{{ActionID: "test_action", Sort: "random"}})
+	if err == nil || !strings.Contains(err.Error(), "unknown sort order") {
+		t.Errorf("Expected an unknown sort order error, got %v", err)
+	}
+}
+
+func TestHandleRequest_PinnedOptionsComeFirst(t *testing.T) {
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID: "test_action",
+			Sort:     sortAlpha,
+			Options: []Option{
+				{Text: "Zeta", Value: "zeta"},
+				{Text: "Pinned B", Value: "pinned-b", Pinned: true},
+				{Text: "Alpha", Value: "alpha"},
+				{Text: "Pinned A", Value: "pinned-a", Pinned: true},
+				{Text: "Pinned Old", Value: "pinned-old", Pinned: true, Tags: []string{"archived"}},
+			},
+			DefaultFilters: []string{"-#archived"},
+		},
+	})
+	secret := "test-secret"
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "", expected: []string{"pinned-a", "pinned-b", "alpha", "zeta"}},
+		{query: "zeta", expected: []string{"pinned-a", "pinned-b", "zeta"}},
+		{query: "nothing", expected: []string{"pinned-a", "pinned-b"}},
+		{query: "#archived", expected: []string{"pinned-old"}},
+		{query: "-#archived zeta", expected: []string{"pinned-a", "pinned-b", "zeta"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			response := decodeTestResponse(t, sendTestRequest(t, secret, SlackRequest{
+				Type:     "block_suggestion",
+				ActionID: "test_action",
+				Value:    tt.query,
+			}))
+
+			got := make([]string, len(response.Options))
+			for i, opt := range response.Options {
+				got[i] = opt.Value
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestHandleRequest_SortsBeforeTruncating(t *testing.T) {
+	options := make([]Option, maxSlackOptions+20)
+	for i := range options {
+		options[i] = Option{Text: fmt.Sprintf("Repo %d", i), Value: fmt.Sprintf("repo-%d", i), Weight: i}
+	}
+	setTestCatalog([]CatalogEntry{{ActionID: "test_action", Sort: sortWeight, Options: options}})
+	secret := "test-secret"
+
+	response := decodeTestResponse(t, sendTestRequest(t, secret, SlackRequest{
+		Type:     "block_suggestion",
+		ActionID: "test_action",
+	}))
+
+	if len(response.Options) != maxSlackOptions {
+		t.Fatalf("Expected %d options, got %d", maxSlackOptions, len(response.Options))
+	}
+	if first := response.Options[0].Value; first != fmt.Sprintf("repo-%d", len(options)-1) {
+		t.Errorf("Expected the heaviest option first, got '%s'", first)
+	}
+}
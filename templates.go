@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// templateVarPattern matches "{{name}}" catalog variable references,
+	// including escaped ones preceded by a backslash
+	templateVarPattern = regexp.MustCompile(`\\?\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+	// envVarPattern matches "${NAME}" environment variable references,
+	// including escaped ones preceded by a backslash
+	envVarPattern = regexp.MustCompile(`\\?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// catalogFile represents a catalog file. The file is either a bare array of
+// entries or an object with global variables and entries.
+type catalogFile struct {
+	Vars    map[string]string `json:"vars,omitempty"`
+	Entries []CatalogEntry    `json:"entries"`
+}
+
+// parseCatalogFile parses a catalog file in either of its two forms
+func parseCatalogFile(data []byte) (catalogFile, error) {
+	var file catalogFile
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(data, &file.Entries)
+		return file, err
+	}
+	err := json.Unmarshal(data, &file)
+	return file, err
+}
+
+// templateExpander expands variables in a single string, collecting the
+// names of undefined variables rather than failing on the first one
+type templateExpander struct {
+	scopes    []map[string]string // searched in order, most specific first
+	undefined []string
+}
+
+// expandEnv replaces ${NAME} with environment variables, and \${NAME} with
+// a literal ${NAME}
+func (x *templateExpander) expandEnv(s string) string {
+	return envVarPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if literal, ok := strings.CutPrefix(match, `\`); ok {
+			return literal
+		}
+		name := envVarPattern.FindStringSubmatch(match)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			x.undefined = append(x.undefined, "${"+name+"}")
+		}
+		return value
+	})
+}
+
+// expand replaces ${NAME} with environment variables and then {{name}} with
+// catalog variables. Either is kept literally when escaped with a backslash.
+func (x *templateExpander) expand(s string) string {
+	return templateVarPattern.ReplaceAllStringFunc(x.expandEnv(s), func(match string) string {
+		if literal, ok := strings.CutPrefix(match, `\`); ok {
+			return literal
+		}
+		name := templateVarPattern.FindStringSubmatch(match)[1]
+		for _, scope := range x.scopes {
+			if value, ok := scope[name]; ok {
+				return value
+			}
+		}
+		x.undefined = append(x.undefined, "{{"+name+"}}")
+		return ""
+	})
+}
+
+// expandAll expands every string in a slice in place
+func (x *templateExpander) expandAll(values []string) {
+	for i := range values {
+		values[i] = x.expand(values[i])
+	}
+}
+
+// expandVars expands environment variables in the values of a variable set.
+// Variables cannot reference other catalog variables.
+func (x *templateExpander) expandVars(vars map[string]string) map[string]string {
+	expanded := make(map[string]string, len(vars))
+	for name, value := range vars {
+		expanded[name] = x.expandEnv(value)
+	}
+	return expanded
+}
+
+// err reports the undefined variables found so far, if any
+func (x *templateExpander) err(context string) error {
+	if len(x.undefined) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: undefined variable(s) %v", context, x.undefined)
+}
+
+// expandCatalog expands templates in the options of every entry in place.
+// Option variables take precedence over entry variables, which take
+// precedence over the file's global variables.
+func expandCatalog(file *catalogFile) error {
+	var errs []error
+
+	global := &templateExpander{}
+	globalVars := global.expandVars(file.Vars)
+	if err := global.err("vars"); err != nil {
+		errs = append(errs, err)
+	}
+
+	for i := range file.Entries {
+		entry := &file.Entries[i]
+		context := fmt.Sprintf("entry %d (action '%s')", i, entry.ActionID)
+
+		entryExpander := &templateExpander{}
+		entryVars := entryExpander.expandVars(entry.Vars)
+		entryExpander.scopes = []map[string]string{entryVars, globalVars}
+
+		for j := range entry.Options {
+			if err := expandOption(&entry.Options[j], entryExpander.scopes); err != nil {
+				errs = append(errs, fmt.Errorf("%s option %d: %w", context, j, err))
+			}
+		}
+
+		if entry.OptionsByValue != nil {
+			byValue := make(map[string][]Option, len(entry.OptionsByValue))
+			original := make(map[string]string, len(entry.OptionsByValue))
+			for _, key := range slices.Sorted(maps.Keys(entry.OptionsByValue)) {
+				options := entry.OptionsByValue[key]
+				for j := range options {
+					if err := expandOption(&options[j], entryExpander.scopes); err != nil {
+						errs = append(errs, fmt.Errorf("%s optionsByValue[%q] option %d: %w", context, key, j, err))
+					}
+				}
+				expanded := entryExpander.expand(key)
+				if previous, ok := original[expanded]; ok {
+					errs = append(errs, fmt.Errorf("%s: optionsByValue keys %q and %q both expand to %q", context, previous, key, expanded))
+					continue
+				}
+				original[expanded] = key
+				byValue[expanded] = options
+			}
+			entry.OptionsByValue = byValue
+		}
+
+		if err := entryExpander.err(context); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// expandOption expands templates in the text fields of a single option
+func expandOption(opt *Option, scopes []map[string]string) error {
+	x := &templateExpander{}
+	optVars := x.expandVars(opt.Vars)
+	x.scopes = append([]map[string]string{optVars}, scopes...)
+
+	opt.Text = x.expand(opt.Text)
+	opt.Value = x.expand(opt.Value)
+	opt.Description = x.expand(opt.Description)
+	x.expandAll(opt.Aliases)
+	x.expandAll(opt.Keywords)
+	x.expandAll(opt.Tags)
+	return x.err("expanding templates")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestCatalog writes catalog JSON to a temporary file and returns its path
+func writeTestCatalog(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Failed to write catalog: %v", err)
+	}
+	return path
+}
+
+func TestLoadCatalog_ExpandsTemplates(t *testing.T) {
+	t.Setenv("TEST_GITHUB_ORG", "its-the-vibe")
+	path := writeTestCatalog(t, `{
+		"vars": {"org": "${TEST_GITHUB_ORG}", "host": "github.com"},
+		"entries": [
+			{
+				"actionId": "repos",
+				"vars": {"host": "git.example.com"},
+				"options": [
+					{"text": "{{name}}", "value": "https://{{host}}/{{org}}/{{ name }}", "vars": {"name": "InnerGate"}},
+					{"text": "OctoSlack", "value": "https://{{host}}/{{org}}/OctoSlack", "vars": {"host": "github.com"}, "keywords": ["{{org}}"]}
+				],
+				"optionsByValue": {
+					"{{org}}": [{"text": "Org", "value": "{{org}}"}]
+				}
+			}
+		]
+	}`)
+
+	if err := loadCatalog(path); err != nil {
+		t.Fatalf("Failed to load catalog: %v", err)
+	}
+
+	options := currentCatalog().entries[0].Options
+	if options[0].Text != "InnerGate" || options[0].Value != "https://git.example.com/its-the-vibe/InnerGate" {
+		t.Errorf("Unexpected first option: %+v", options[0])
+	}
+	if options[1].Value != "https://github.com/its-the-vibe/OctoSlack" {
+		t.Errorf("Expected option variables to take precedence, got '%s'", options[1].Value)
+	}
+	if options[1].Keywords[0] != "its-the-vibe" {
+		t.Errorf("Expected keywords to be expanded, got %v", options[1].Keywords)
+	}
+
+	byValue, ok := currentCatalog().entries[0].OptionsByValue["its-the-vibe"]
+	if !ok || byValue[0].Value != "its-the-vibe" {
+		t.Errorf("Expected optionsByValue to be expanded, got %v", currentCatalog().entries[0].OptionsByValue)
+	}
+}
+
+func TestLoadCatalog_ArrayFormat(t *testing.T) {
+	path := writeTestCatalog(t, `[{"actionId": "repos", "options": [{"text": "InnerGate", "value": "InnerGate"}]}]`)
+
+	if err := loadCatalog(path); err != nil {
+		t.Fatalf("Failed to load catalog: %v", err)
+	}
+	if len(currentCatalog().entries) != 1 || currentCatalog().entries[0].Options[0].Value != "InnerGate" {
+		t.Errorf("Unexpected catalog: %+v", currentCatalog().entries)
+	}
+}
+
+func TestLoadCatalog_EscapedTemplates(t *testing.T) {
+	os.Unsetenv("TEST_MISSING_VAR")
+	path := writeTestCatalog(t, `[{"actionId": "docs", "options": [
+		{"text": "Template \\{{name}}", "value": "\\${TEST_MISSING_VAR}"}
+	]}]`)
+
+	if err := loadCatalog(path); err != nil {
+		t.Fatalf("Failed to load catalog: %v", err)
+	}
+	opt := currentCatalog().entries[0].Options[0]
+	if opt.Text != "Template {{name}}" || opt.Value != "${TEST_MISSING_VAR}" {
+		t.Errorf("Expected escaped references to be kept literally, got %q and %q", opt.Text, opt.Value)
+	}
+}
+
+func TestLoadCatalog_CollidingDependentKeys(t *testing.T) {
+	path := writeTestCatalog(t, `[{
+		"actionId": "environment",
+		"dependsOn": "stage",
+		"vars": {"e": "prod"},
+		"options": [],
+		"optionsByValue": {
+			"{{e}}": [{"text": "A", "value": "a"}],
+			"prod": [{"text": "B", "value": "b"}]
+		}
+	}]`)
+
+	err := loadCatalog(path)
+	if err == nil || !strings.Contains(err.Error(), `optionsByValue keys "prod" and "{{e}}" both expand to "prod"`) {
+		t.Errorf("Expected an error for colliding keys, got %v", err)
+	}
+}
+
+func TestLoadCatalog_UndefinedVariables(t *testing.T) {
+	os.Unsetenv("TEST_MISSING_VAR")
+	path := writeTestCatalog(t, `{
+		"vars": {"org": "${TEST_MISSING_VAR}"},
+		"entries": [
+			{"actionId": "repos", "options": [
+				{"text": "{{name}}", "value": "{{org}}/{{missing}}"}
+			]}
+		]
+	}`)
+
+	err := loadCatalog(path)
+	if err == nil {
+		t.Fatal("Expected an error for undefined variables")
+	}
+	for _, name := range []string{"${TEST_MISSING_VAR}", "{{name}}", "{{missing}}", "action 'repos'"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected error to mention %s, got: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// activeAt reports whether an option's validity window includes t. The
+// window includes ValidFrom and excludes ValidUntil; either end may be open.
+func (o Option) activeAt(t time.Time) bool {
+	if o.ValidFrom != nil && t.Before(*o.ValidFrom) {
+		return false
+	}
+	if o.ValidUntil != nil && !t.Before(*o.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// checkTimeWindows rejects options whose window ends before it starts and
+// logs a warning for each option that has already expired
+func checkTimeWindows(index int, entry CatalogEntry, now time.Time) error {
+	check := func(opt Option) error {
+		if opt.ValidFrom != nil && opt.ValidUntil != nil && opt.ValidUntil.Before(*opt.ValidFrom) {
+			return fmt.Errorf("entry %d (action '%s'): option '%s' has validUntil before validFrom", index, entry.ActionID, opt.Value)
+		}
+		if opt.ValidUntil != nil && !now.Before(*opt.ValidUntil) {
+			log.Printf("Warning: entry %d (action '%s'): option '%s' expired at %s", index, entry.ActionID, opt.Value, opt.ValidUntil.Format(time.RFC3339))
+		}
+		return nil
+	}
+
+	for _, opt := range entry.Options {
+		if err := check(opt); err != nil {
+			return err
+		}
+	}
+	for _, options := range entry.OptionsByValue {
+		for _, opt := range options {
+			if err := check(opt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// windowBoundaries returns the sorted times at which any of the options
+// becomes active or expires
+func windowBoundaries(options []Option) []time.Time {
+	var boundaries []time.Time
+	for _, opt := range options {
+		if opt.ValidFrom != nil {
+			boundaries = append(boundaries, *opt.ValidFrom)
+		}
+		if opt.ValidUntil != nil {
+			boundaries = append(boundaries, *opt.ValidUntil)
+		}
+	}
+	slices.SortFunc(boundaries, time.Time.Compare)
+	return boundaries
+}
+
+// nextBoundary returns the first boundary after now, if there is one
+func nextBoundary(boundaries []time.Time, now time.Time) (time.Time, bool) {
+	i, _ := slices.BinarySearchFunc(boundaries, now, func(b, t time.Time) int {
+		if b.After(t) {
+			return 1
+		}
+		return -1
+	})
+	if i == len(boundaries) {
+		return time.Time{}, false
+	}
+	return boundaries[i], true
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// timePtr returns a pointer to t
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestOption_ActiveAt(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		opt      Option
+		at       time.Time
+		expected bool
+	}{
+		{name: "no window", opt: Option{}, at: start, expected: true},
+		{name: "before start", opt: Option{ValidFrom: &start}, at: start.Add(-time.Second), expected: false},
+		{name: "at start", opt: Option{ValidFrom: &start}, at: start, expected: true},
+		{name: "before end", opt: Option{ValidUntil: &end}, at: end.Add(-time.Second), expected: true},
+		{name: "at end", opt: Option{ValidUntil: &end}, at: end, expected: false},
+		{name: "inside window", opt: Option{ValidFrom: &start, ValidUntil: &end}, at: start.Add(24 * time.Hour), expected: true},
+		{name: "after window", opt: Option{ValidFrom: &start, ValidUntil: &end}, at: end.Add(24 * time.Hour), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opt.activeAt(tt.at); got != tt.expected {
+				t.Errorf("activeAt() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHandleRequest_HidesOptionsOutsideTimeWindow(t *testing.T) {
+	now := time.Now()
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID: "test_action",
+			Options: []Option{
+				{Text: "Always", Value: "always"},
+				{Text: "Current", Value: "current", ValidFrom: timePtr(now.Add(-time.Hour)), ValidUntil: timePtr(now.Add(time.Hour))},
+				{Text: "Upcoming", Value: "upcoming", ValidFrom: timePtr(now.Add(time.Hour))},
+				{Text: "Expired", Value: "expired", ValidUntil: timePtr(now.Add(-time.Hour))},
+			},
+		},
+	})
+	secret := "test-secret"
+
+	response := decodeTestResponse(t, sendTestRequest(t, secret, SlackRequest{
+		Type:     "block_suggestion",
+		ActionID: "test_action",
+	}))
+
+	if len(response.Options) != 2 {
+		t.Fatalf("Expected 2 options, got %d", len(response.Options))
+	}
+	if response.Options[0].Value != "always" || response.Options[1].Value != "current" {
+		t.Errorf("Unexpected options: %+v", response.Options)
+	}
+}
+
+func TestSetCatalog_TimeWindowValidation(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	now := time.Now()
+	err := setCatalog([]CatalogEntry{
+		{
+			ActionID: "test_action",
+			Options:  []Option{{Text: "Expired", Value: "expired", ValidUntil: timePtr(now.Add(-time.Hour))}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(logs.String(), "option 'expired' expired at") {
+		t.Errorf("Expected an expiry warning, got logs: %s", logs.String())
+	}
+
+	err = setCatalog([]CatalogEntry{
+		{
+			ActionID: "test_action",
+			Options:  []Option{{Text: "Inverted", Value: "inverted", ValidFrom: timePtr(now), ValidUntil: timePtr(now.Add(-time.Hour))}},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "validUntil before validFrom") {
+		t.Errorf("Expected an inverted window error, got %v", err)
+	}
+}
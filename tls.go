@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsVersions maps the TLS_MIN_VERSION setting to TLS protocol versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// certReloader serves a certificate and key from disk, reloading them when
+// either file is modified so rotated certificates are picked up without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+	lastErr error // last reload error, so it is logged once rather than on every handshake
+}
+
+// newCertReloader loads a certificate and key, failing if they are invalid
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reloadIfModified(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reloadIfModified reloads the certificate and key if either file has changed
+// since they were last loaded. The caller must not hold r.mu.
+func (r *certReloader) reloadIfModified() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("reading TLS certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("reading TLS key: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cert != nil && certInfo.ModTime().Equal(r.certMod) && keyInfo.ModTime().Equal(r.keyMod) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	if r.cert != nil {
+		log.Printf("Reloaded TLS certificate from %s", r.certFile)
+	}
+	r.cert = &cert
+	r.certMod = certInfo.ModTime()
+	r.keyMod = keyInfo.ModTime()
+	return nil
+}
+
+// getCertificate implements tls.Config.GetCertificate. If a rotated
+// certificate cannot be loaded, for instance because only one of the two
+// files has been replaced so far, the previous one keeps being served.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	err := r.reloadIfModified()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil && (r.lastErr == nil || r.lastErr.Error() != err.Error()) {
+		log.Printf("Error reloading TLS certificate, keeping the current one: %v", err)
+	}
+	r.lastErr = err
+	return r.cert, nil
+}
+
+// newTLSConfig builds the server's TLS configuration, or returns nil if TLS
+// is not configured. With a client CA, clients may present a certificate
+// signed by it; requireClientCert then restricts endpoints to such clients.
+func newTLSConfig(config Config) (*tls.Config, error) {
+	if config.TLSCertFile == "" && config.TLSKeyFile == "" {
+		if config.TLSClientCAFile != "" {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	if config.TLSCertFile == "" || config.TLSKeyFile == "" {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	minVersion, ok := tlsVersions[config.TLSMinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS_MIN_VERSION '%s'", config.TLSMinVersion)
+	}
+
+	reloader, err := newCertReloader(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.getCertificate,
+	}
+
+	if config.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(config.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading TLS client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in TLS client CA file %s", config.TLSClientCAFile)
+		}
+		// Slack does not present client certificates, so they are only
+		// verified when given and enforced per endpoint
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// requireClientCert restricts an endpoint to clients that presented a
+// certificate signed by the client CA. It is a no-op unless required is set.
+func requireClientCert(required bool, next http.Handler) http.Handler {
+	if !required {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			log.Printf("Rejected request to %s without a verified client certificate", r.URL.Path)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a generated certificate with its PEM encoding
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// generateTestCert creates a certificate for localhost, signed by parent or
+// self-signed if parent is nil
+func generateTestCert(t *testing.T, name string, isCA bool, parent *testCert) testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("Failed to generate serial: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	return testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeTestCert writes a certificate and key to dir with the given modification time
+func writeTestCert(t *testing.T, dir string, c testCert, modTime time.Time) (string, string) {
+	t.Helper()
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	for path, data := range map[string][]byte{certFile: c.certPEM, keyFile: c.keyPEM} {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set the modification time of %s: %v", path, err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func TestCertReloader_ReloadsRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	first := generateTestCert(t, "first", false, nil)
+	certFile, keyFile := writeTestCert(t, dir, first, time.Now().Add(-time.Minute))
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	cert, _ := reloader.getCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "first" {
+		t.Fatalf("Expected the first certificate, got %s", cert.Leaf.Subject.CommonName)
+	}
+
+	second := generateTestCert(t, "second", false, nil)
+	writeTestCert(t, dir, second, time.Now())
+	cert, _ = reloader.getCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "second" {
+		t.Errorf("Expected the rotated certificate, got %s", cert.Leaf.Subject.CommonName)
+	}
+
+	// A half-written rotation keeps the current certificate
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	cert, err = reloader.getCertificate(nil)
+	if err != nil || cert.Leaf.Subject.CommonName != "second" {
+		t.Errorf("Expected the current certificate to be kept, got %v (%v)", cert, err)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, generateTestCert(t, "server", false, nil), time.Now())
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "disabled", config: Config{TLSMinVersion: "1.2"}, wantNil: true},
+		{name: "enabled", config: Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.3"}},
+		{name: "missing key", config: Config{TLSCertFile: certFile, TLSMinVersion: "1.2"}, wantErr: true},
+		{name: "unknown version", config: Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "2.0"}, wantErr: true},
+		{name: "client CA without TLS", config: Config{TLSClientCAFile: certFile, TLSMinVersion: "1.2"}, wantErr: true},
+		{name: "invalid client CA", config: Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.2", TLSClientCAFile: keyFile}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTLSConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && (got == nil) != tt.wantNil {
+				t.Errorf("Expected nil config %v, got %+v", tt.wantNil, got)
+			}
+		})
+	}
+
+	got, _ := newTLSConfig(Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.3"})
+	if got.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected minimum version TLS 1.3, got %x", got.MinVersion)
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	dir := t.TempDir()
+	ca := generateTestCert(t, "client-ca", true, nil)
+	client := generateTestCert(t, "admin", false, &ca)
+	untrusted := generateTestCert(t, "stranger", false, nil)
+	certFile, keyFile := writeTestCert(t, dir, generateTestCert(t, "server", false, nil), time.Now())
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, ca.certPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write CA: %v", err)
+	}
+
+	tlsConfig, err := newTLSConfig(Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.2", TLSClientCAFile: caFile})
+	if err != nil {
+		t.Fatalf("Failed to configure TLS: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/admin", requireClientCert(true, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server := httptest.NewUnstartedServer(mux)
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(path string, cert *testCert) int {
+		t.Helper()
+		clientTLS := &tls.Config{InsecureSkipVerify: true}
+		if cert != nil {
+			pair, err := tls.X509KeyPair(cert.certPEM, cert.keyPEM)
+			if err != nil {
+				t.Fatalf("Failed to load client certificate: %v", err)
+			}
+			clientTLS.Certificates = []tls.Certificate{pair}
+		}
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+		resp, err := httpClient.Get(server.URL + path)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		cert     *testCert
+		expected int
+	}{
+		{name: "public endpoint without certificate", path: "/", expected: http.StatusOK},
+		{name: "admin endpoint without certificate", path: "/admin", expected: http.StatusForbidden},
+		{name: "admin endpoint with trusted certificate", path: "/admin", cert: &client, expected: http.StatusOK},
+		{name: "admin endpoint with untrusted certificate", path: "/admin", cert: &untrusted, expected: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := get(tt.path, tt.cert); got != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
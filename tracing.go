@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName is the instrumentation scope of the spans this service creates
+	tracerName = "github.com/its-the-vibe/OctoCatalog"
+	// serviceName is reported as the service.name of exported spans
+	serviceName = "octocatalog"
+	// defaultTracesPath is the OTLP/HTTP path of traces, used when the
+	// endpoint is given without a path
+	defaultTracesPath = "/v1/traces"
+)
+
+// startSpan starts a span as a child of the span in ctx, if any. Until
+// setupTracing installs a tracer provider, spans are not recorded.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends a span, marking it as failed if err is non-nil
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceHandler wraps an HTTP handler in a server span named after the
+// operation, continuing any trace propagated in the request headers
+func traceHandler(operation string, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, operation, otelhttp.WithSpanNameFormatter(func(operation string, _ *http.Request) string {
+		return operation
+	}))
+}
+
+// requestAttributes describes a Slack request on a span. The query is left
+// out as it may hold whatever the user typed.
+func requestAttributes(req SlackRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("slack.type", req.Type),
+		attribute.String("slack.action_id", req.ActionID),
+		attribute.String("slack.team_id", req.Team.ID),
+	}
+}
+
+// newTracerProvider builds a tracer provider batching the sampled ratio of
+// traces to an exporter
+func newTracerProvider(exporter sdktrace.SpanExporter, sampleRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+}
+
+// newTraceExporter creates an OTLP/HTTP exporter sending spans to an
+// endpoint URL. A URL without a path, such as a collector's
+// http://localhost:4318, gets the standard traces path.
+func newTraceExporter(endpointURL string) (sdktrace.SpanExporter, error) {
+	endpoint, err := url.Parse(endpointURL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("tracing endpoint %q must be an http or https URL", endpointURL)
+	}
+	if endpoint.Path == "" || endpoint.Path == "/" {
+		endpoint.Path = defaultTracesPath
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint.String()))
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// setupTracing exports spans over OTLP/HTTP to the configured endpoint.
+// Tracing is disabled when no endpoint is set.
+func setupTracing(config Config) error {
+	if config.TracingEndpoint == "" {
+		return nil
+	}
+	exporter, err := newTraceExporter(config.TracingEndpoint)
+	if err != nil {
+		return err
+	}
+	otel.SetTracerProvider(newTracerProvider(exporter, config.TracingSampleRatio))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	log.Printf("Exporting traces to %s", config.TracingEndpoint)
+	return nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// installTestTracer records spans in memory until the test ends
+func installTestTracer(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(t.Context())
+	})
+	return exporter
+}
+
+// spanNamed returns the first recorded span with the given name
+func spanNamed(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("Expected a %s span, got %v", name, spanNames(spans))
+	return tracetest.SpanStub{}
+}
+
+// spanNames returns the names of recorded spans in the order they ended
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name
+	}
+	return names
+}
+
+// spanAttribute returns the value of a span attribute, or an invalid value if unset
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing_RequestStages(t *testing.T) {
+	catalog := &catalogStore{}
+	if err := catalog.set([]CatalogEntry{{ActionID: "env", Options: []Option{{Text: "Production", Value: "prod"}}}}); err != nil {
+		t.Fatalf("Failed to set catalog: %v", err)
+	}
+	apps := []*slackApp{{
+		config:        AppConfig{Name: defaultAppName, OptionsPath: "/slack/options", CommandsPath: "/slack/commands"},
+		catalog:       catalog,
+		signingSecret: staticSecret("secret"),
+	}}
+
+	suggestion, err := json.Marshal(SlackRequest{Type: "block_suggestion", ActionID: "env", Team: SlackTeam{ID: "T1"}})
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %v", err)
+	}
+	command := []byte(url.Values{"command": {"/catalog"}, "text": {"env prod"}}.Encode())
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        []byte
+		root        string
+	}{
+		{name: "interaction", path: "/slack/options", contentType: "application/json", body: suggestion, root: "slack.interaction"},
+		{name: "slash command", path: "/slack/commands", contentType: "application/x-www-form-urlencoded", body: command, root: "slack.command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := installTestTracer(t)
+			mux := newServeMux(apps, nil)
+
+			rr := sendSignedRequestTo(t, mux, tt.path, tt.contentType, "secret", tt.body)
+			if rr.Code != 200 {
+				t.Fatalf("Expected status 200, got %d", rr.Code)
+			}
+
+			spans := exporter.GetSpans()
+			root := spanNamed(t, spans, tt.root)
+			for _, name := range []string{"slack.verify", "slack.parse", "catalog.lookup", "catalog.filter", "slack.encode"} {
+				span := spanNamed(t, spans, name)
+				if span.Parent.SpanID() != root.SpanContext.SpanID() {
+					t.Errorf("Expected %s to be a child of %s", name, tt.root)
+				}
+			}
+
+			filter := spanNamed(t, spans, "catalog.filter")
+			if got := spanAttribute(filter, "catalog.options").AsInt64(); got != 1 {
+				t.Errorf("Expected catalog.options 1, got %d", got)
+			}
+		})
+	}
+}
+
+func TestTracing_EncodesOnceWithResponseCache(t *testing.T) {
+	setupTestCatalog()
+	responses = newResponseCache(10)
+	defer func() { responses = nil }()
+
+	tests := []struct {
+		name    string
+		encodes int
+	}{
+		{name: "miss", encodes: 1},
+		{name: "hit", encodes: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := installTestTracer(t)
+			rr := sendTestRequest(t, "secret", SlackRequest{Type: "block_suggestion", ActionID: "test_action"})
+			if rr.Code != 200 {
+				t.Fatalf("Expected status 200, got %d", rr.Code)
+			}
+
+			encodes := 0
+			for _, name := range spanNames(exporter.GetSpans()) {
+				if name == "slack.encode" {
+					encodes++
+				}
+			}
+			if encodes != tt.encodes {
+				t.Errorf("Expected %d slack.encode span(s), got %d", tt.encodes, encodes)
+			}
+		})
+	}
+}
+
+func TestTracing_RequestAttributes(t *testing.T) {
+	exporter := installTestTracer(t)
+	setupTestCatalog()
+
+	rr := sendTestRequest(t, "secret", SlackRequest{Type: "block_suggestion", ActionID: "test_action", Value: "private query", Team: SlackTeam{ID: "T1"}})
+	if rr.Code != 200 {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	// Without traceHandler there is no root span, so the attributes are only
+	// checked on the stages that record them
+	lookup := spanNamed(t, exporter.GetSpans(), "catalog.lookup")
+	if !spanAttribute(lookup, "catalog.found").AsBool() {
+		t.Error("Expected catalog.found to be true")
+	}
+	for _, span := range exporter.GetSpans() {
+		for _, attr := range span.Attributes {
+			if attr.Value.Emit() == "private query" {
+				t.Errorf("Expected the query not to be recorded, found it in %s on %s", attr.Key, span.Name)
+			}
+		}
+	}
+}
+
+func TestTracing_CatalogLoad(t *testing.T) {
+	exporter := installTestTracer(t)
+
+	valid := writeTestCatalog(t, `[{"actionId": "env", "options": [{"text": "Production", "value": "prod"}]}]`)
+	invalid := writeTestCatalog(t, `[{"actionId": "env", "options": [{"text": "Production", "value": "prod"}, {"text": "Production", "value": "prod", "pinned": 1}]`)
+
+	catalog := &catalogStore{}
+	if err := catalog.load(valid); err != nil {
+		t.Fatalf("Failed to load catalog: %v", err)
+	}
+	if err := catalog.load(invalid); err == nil {
+		t.Fatal("Expected an error loading an invalid catalog")
+	}
+
+	spans := exporter.GetSpans()
+	if names := spanNames(spans); !slices.Equal(names, []string{"catalog.load", "catalog.load"}) {
+		t.Fatalf("Expected two catalog.load spans, got %v", names)
+	}
+	if got := spanAttribute(spans[0], "catalog.entries").AsInt64(); got != 1 {
+		t.Errorf("Expected catalog.entries 1, got %d", got)
+	}
+	if got := spanAttribute(spans[0], "catalog.file").AsString(); got != valid {
+		t.Errorf("Expected catalog.file %s, got %s", valid, got)
+	}
+	if spans[0].Status.Code != codes.Unset {
+		t.Errorf("Expected the first load to succeed, got status %v", spans[0].Status)
+	}
+	if spans[1].Status.Code != codes.Error {
+		t.Errorf("Expected the second load to fail, got status %v", spans[1].Status)
+	}
+}
+
+func TestSetupTracing_DisabledByDefault(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	if err := setupTracing(defaultConfig()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if otel.GetTracerProvider() != previous {
+		t.Error("Expected the tracer provider to be left unchanged")
+	}
+}
+
+func TestNewTraceExporter_Paths(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		expected string
+	}{
+		{name: "no path", endpoint: collector.URL, expected: "/v1/traces"},
+		{name: "root path", endpoint: collector.URL + "/", expected: "/v1/traces"},
+		{name: "custom path", endpoint: collector.URL + "/otlp/v1/traces", expected: "/otlp/v1/traces"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			paths = nil
+			mu.Unlock()
+
+			exporter, err := newTraceExporter(tt.endpoint)
+			if err != nil {
+				t.Fatalf("Failed to create exporter: %v", err)
+			}
+			provider := newTracerProvider(exporter, 1)
+			_, span := provider.Tracer(tracerName).Start(t.Context(), "test")
+			span.End()
+			if err := provider.Shutdown(t.Context()); err != nil {
+				t.Fatalf("Failed to export spans: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !slices.Equal(paths, []string{tt.expected}) {
+				t.Errorf("Expected spans to be posted to %s, got %v", tt.expected, paths)
+			}
+		})
+	}
+}
+
+func TestSetupTracing_InvalidEndpoint(t *testing.T) {
+	config := defaultConfig()
+	config.TracingEndpoint = "://not a url"
+	if err := setupTracing(config); err == nil {
+		t.Error("Expected an error for an invalid endpoint")
+	}
+}
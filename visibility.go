@@ -0,0 +1,86 @@
+package main
+
+// Visibility restricts which channels, users and teams can see a catalog
+// entry or option. Deny lists always win. When an allow list is set, the
+// request must carry an ID on that list; requests where the ID is unknown
+// (e.g. no channel in a modal) are therefore denied.
+type Visibility struct {
+	AllowChannels []string `json:"allowChannels,omitempty"`
+	DenyChannels  []string `json:"denyChannels,omitempty"`
+	AllowUsers    []string `json:"allowUsers,omitempty"`
+	DenyUsers     []string `json:"denyUsers,omitempty"`
+	AllowTeams    []string `json:"allowTeams,omitempty"`
+	DenyTeams     []string `json:"denyTeams,omitempty"`
+}
+
+// requestScope identifies the team, user and channel a request originates from
+type requestScope struct {
+	TeamID    string
+	UserID    string
+	ChannelID string
+}
+
+// scopeOf extracts the request scope from a Slack payload. The channel is
+// taken from the payload's channel, falling back to its container.
+func scopeOf(req SlackRequest) requestScope {
+	channelID := req.Channel.ID
+	if channelID == "" {
+		channelID = req.Container.ChannelID
+	}
+	return requestScope{
+		TeamID:    req.Team.ID,
+		UserID:    req.User.ID,
+		ChannelID: channelID,
+	}
+}
+
+// scopeMask records which parts of a request scope some visibility rules look at
+type scopeMask struct {
+	team, user, channel bool
+}
+
+// add widens the mask to the parts of the scope the rules look at
+func (m *scopeMask) add(v *Visibility) {
+	if v == nil {
+		return
+	}
+	m.team = m.team || len(v.AllowTeams) > 0 || len(v.DenyTeams) > 0
+	m.user = m.user || len(v.AllowUsers) > 0 || len(v.DenyUsers) > 0
+	m.channel = m.channel || len(v.AllowChannels) > 0 || len(v.DenyChannels) > 0
+}
+
+// apply clears the parts of a scope the mask does not cover, so requests the
+// rules cannot tell apart get the same scope
+func (m scopeMask) apply(scope requestScope) requestScope {
+	if !m.team {
+		scope.TeamID = ""
+	}
+	if !m.user {
+		scope.UserID = ""
+	}
+	if !m.channel {
+		scope.ChannelID = ""
+	}
+	return scope
+}
+
+// allows reports whether the rules permit a request scope. A nil Visibility allows everything.
+func (v *Visibility) allows(scope requestScope) bool {
+	if v == nil {
+		return true
+	}
+	return allowedBy(v.AllowTeams, v.DenyTeams, scope.TeamID) &&
+		allowedBy(v.AllowUsers, v.DenyUsers, scope.UserID) &&
+		allowedBy(v.AllowChannels, v.DenyChannels, scope.ChannelID)
+}
+
+// allowedBy evaluates a single allow/deny list pair against an ID
+func allowedBy(allow, deny []string, id string) bool {
+	if id != "" && containsString(deny, id) {
+		return false
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	return id != "" && containsString(allow, id)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestVisibility_Allows(t *testing.T) {
+	scope := requestScope{TeamID: "T1", UserID: "U1", ChannelID: "C1"}
+
+	tests := []struct {
+		name       string
+		visibility *Visibility
+		scope      requestScope
+		expected   bool
+	}{
+		{name: "nil allows everything", visibility: nil, scope: scope, expected: true},
+		{name: "empty allows everything", visibility: &Visibility{}, scope: scope, expected: true},
+		{name: "allowed channel", visibility: &Visibility{AllowChannels: []string{"C1", "C2"}}, scope: scope, expected: true},
+		{name: "channel not allowed", visibility: &Visibility{AllowChannels: []string{"C2"}}, scope: scope, expected: false},
+		{name: "denied channel", visibility: &Visibility{DenyChannels: []string{"C1"}}, scope: scope, expected: false},
+		{name: "denied user", visibility: &Visibility{DenyUsers: []string{"U1"}}, scope: scope, expected: false},
+		{name: "allowed user", visibility: &Visibility{AllowUsers: []string{"U1"}}, scope: scope, expected: true},
+		{name: "team not allowed", visibility: &Visibility{AllowTeams: []string{"T2"}}, scope: scope, expected: false},
+		{name: "deny wins over allow", visibility: &Visibility{AllowUsers: []string{"U1"}, DenyUsers: []string{"U1"}}, scope: scope, expected: false},
+		{name: "all dimensions must allow", visibility: &Visibility{AllowUsers: []string{"U1"}, AllowChannels: []string{"C2"}}, scope: scope, expected: false},
+		{name: "unknown channel fails allow list", visibility: &Visibility{AllowChannels: []string{"C1"}}, scope: requestScope{UserID: "U1"}, expected: false},
+		{name: "unknown channel passes deny list", visibility: &Visibility{DenyChannels: []string{"C1"}}, scope: requestScope{UserID: "U1"}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.visibility.allows(tt.scope); got != tt.expected {
+				t.Errorf("allows() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScopeOf_FallsBackToContainerChannel(t *testing.T) {
+	req := SlackRequest{
+		Team:      SlackTeam{ID: "T1"},
+		User:      SlackUser{ID: "U1"},
+		Container: SlackContainer{Type: "message", ChannelID: "C1"},
+	}
+	expected := requestScope{TeamID: "T1", UserID: "U1", ChannelID: "C1"}
+	if got := scopeOf(req); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	req.Channel = SlackChannel{ID: "C2"}
+	expected.ChannelID = "C2"
+	if got := scopeOf(req); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestHandleRequest_Visibility(t *testing.T) {
+	setTestCatalog([]CatalogEntry{
+		{
+			ActionID: "test_action",
+			Options: []Option{
+				{Text: "Public", Value: "public"},
+				{Text: "Ops Only", Value: "ops", Visibility: &Visibility{AllowChannels: []string{"C_OPS"}}},
+				{Text: "Not For U2", Value: "not-u2", Visibility: &Visibility{DenyUsers: []string{"U2"}}},
+			},
+		},
+		{
+			ActionID:   "restricted_action",
+			Visibility: &Visibility{AllowTeams: []string{"T1"}},
+			Options:    []Option{{Text: "Secret", Value: "secret"}},
+		},
+		{
+			ActionID: "env",
+			Options:  []Option{{Text: "Staging", Value: "staging"}},
+		},
+		{
+			ActionID:   "env",
+			BlockID:    "deploy",
+			Visibility: &Visibility{AllowTeams: []string{"T1"}},
+			Options:    []Option{{Text: "Production", Value: "prod"}},
+		},
+	})
+	secret := "test-secret"
+
+	tests := []struct {
+		name     string
+		req      SlackRequest
+		expected []string
+	}{
+		{
+			name:     "ops channel via container",
+			req:      SlackRequest{ActionID: "test_action", User: SlackUser{ID: "U1"}, Container: SlackContainer{Type: "message", ChannelID: "C_OPS"}},
+			expected: []string{"public", "ops", "not-u2"},
+		},
+		{
+			name:     "other channel",
+			req:      SlackRequest{ActionID: "test_action", User: SlackUser{ID: "U1"}, Channel: SlackChannel{ID: "C_GENERAL"}},
+			expected: []string{"public", "not-u2"},
+		},
+		{
+			name:     "denied user in modal",
+			req:      SlackRequest{ActionID: "test_action", User: SlackUser{ID: "U2"}, Container: SlackContainer{Type: "view", ViewID: "V1"}},
+			expected: []string{"public"},
+		},
+		{
+			name:     "allowed team",
+			req:      SlackRequest{ActionID: "restricted_action", Team: SlackTeam{ID: "T1"}},
+			expected: []string{"secret"},
+		},
+		{
+			name:     "other team",
+			req:      SlackRequest{ActionID: "restricted_action", Team: SlackTeam{ID: "T2"}},
+			expected: []string{},
+		},
+		{
+			name:     "specific route visible",
+			req:      SlackRequest{ActionID: "env", BlockID: "deploy", Team: SlackTeam{ID: "T1"}},
+			expected: []string{"prod"},
+		},
+		{
+			name:     "hidden specific route falls back",
+			req:      SlackRequest{ActionID: "env", BlockID: "deploy", Team: SlackTeam{ID: "T2"}},
+			expected: []string{"staging"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Type = "block_suggestion"
+			response := decodeTestResponse(t, sendTestRequest(t, secret, tt.req))
+
+			if len(response.Options) != len(tt.expected) {
+				t.Fatalf("Expected %d options, got %d", len(tt.expected), len(response.Options))
+			}
+			for i, value := range tt.expected {
+				if response.Options[i].Value != value {
+					t.Errorf("Expected option %d to be '%s', got '%s'", i, value, response.Options[i].Value)
+				}
+			}
+		})
+	}
+}